@@ -15,14 +15,14 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/v1/decrypt": {
+        "/v1/attachments/add": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Decrypts a password-protected PDF file",
+                "description": "Embeds one or more files into a PDF. With relationship set, the files are also registered as\nassociated files of the document, as required for ZUGFeRD/Factur-X invoices (e.g. Alternative or Data).",
                 "consumes": [
                     "multipart/form-data",
                     "application/json"
@@ -33,11 +33,99 @@ const docTemplate = `{
                 "tags": [
                     "PDF Operations"
                 ],
-                "summary": "Decrypt a PDF file",
+                "summary": "Embed files",
                 "parameters": [
                     {
                         "type": "file",
-                        "description": "Encrypted PDF file to decrypt",
+                        "description": "PDF file",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF and attachments array of {filename, base64, description}",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "file",
+                        "description": "File to embed (repeat the field for each file)",
+                        "name": "attachment",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Description, repeat in the order of the attachments",
+                        "name": "description",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "AFRelationship: Source, Data, Alternative, Supplement or Unspecified",
+                        "name": "relationship",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/attachments/extract": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns a single embedded file when names selects exactly one, otherwise a ZIP archive of the selected or all files.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream",
+                    "application/zip"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Extract embedded files",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file",
                         "name": "file",
                         "in": "formData"
                     },
@@ -51,10 +139,15 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Password to decrypt the PDF",
+                        "description": "Password of an encrypted PDF",
                         "name": "pdf_password",
-                        "in": "formData",
-                        "required": true
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated attachment names, defaults to all",
+                        "name": "names",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
@@ -76,6 +169,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.Response"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -85,14 +184,83 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/encrypt": {
+        "/v1/attachments/list": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Encrypts a PDF file with password protection",
+                "description": "Lists the file attachments embedded in a PDF with their name, description, modification time and size.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "List embedded files",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/attachments/remove": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Removes the named file attachments, or all of them with all set.",
                 "consumes": [
                     "multipart/form-data",
                     "application/json"
@@ -103,11 +271,11 @@ const docTemplate = `{
                 "tags": [
                     "PDF Operations"
                 ],
-                "summary": "Encrypt a PDF file",
+                "summary": "Remove embedded files",
                 "parameters": [
                     {
                         "type": "file",
-                        "description": "PDF file to encrypt",
+                        "description": "PDF file",
                         "name": "file",
                         "in": "formData"
                     },
@@ -121,10 +289,21 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Password to encrypt the PDF",
+                        "description": "Password of an encrypted PDF",
                         "name": "pdf_password",
-                        "in": "formData",
-                        "required": true
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated attachment names",
+                        "name": "names",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Remove all attachments",
+                        "name": "all",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
@@ -146,6 +325,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.Response"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -155,29 +340,44 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/ocr": {
+        "/v1/decrypt": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Uploads a PDF file and performs OCR using Mistral OCR API",
+                "description": "Decrypts a password-protected PDF file",
                 "consumes": [
-                    "multipart/form-data"
+                    "multipart/form-data",
+                    "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/octet-stream"
                 ],
                 "tags": [
                     "PDF Operations"
                 ],
-                "summary": "Perform OCR on a PDF file",
+                "summary": "Decrypt a PDF file",
                 "parameters": [
                     {
                         "type": "file",
-                        "description": "PDF file to process",
+                        "description": "Encrypted PDF file to decrypt",
                         "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password to decrypt the PDF",
+                        "name": "pdf_password",
                         "in": "formData",
                         "required": true
                     }
@@ -186,7 +386,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/types.Response"
+                            "type": "file"
                         }
                     },
                     "400": {
@@ -201,8 +401,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.Response"
                         }
                     },
-                    "408": {
-                        "description": "Request Timeout",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/types.Response"
                         }
@@ -210,14 +410,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/optimize": {
+        "/v1/encrypt": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Optimize a PDF file",
+                "description": "Encrypts a PDF file with password protection",
                 "consumes": [
                     "multipart/form-data",
                     "application/json"
@@ -228,11 +428,11 @@ const docTemplate = `{
                 "tags": [
                     "PDF Operations"
                 ],
-                "summary": "Optimize a PDF file",
+                "summary": "Encrypt a PDF file",
                 "parameters": [
                     {
                         "type": "file",
-                        "description": "PDF file to optimize",
+                        "description": "PDF file to encrypt",
                         "name": "file",
                         "in": "formData"
                     },
@@ -243,6 +443,31 @@ const docTemplate = `{
                         "schema": {
                             "type": "object"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password to encrypt the PDF",
+                        "name": "pdf_password",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Owner password, defaults to pdf_password",
+                        "name": "owner_password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated: print, print_high_quality, copy, modify, annotate, fill_forms, assemble, all or none (default)",
+                        "name": "permissions",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "aes-128 or aes-256 (default)",
+                        "name": "algorithm",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
@@ -273,29 +498,29 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/repair": {
+        "/v1/extract-images": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Repair a corrupt or invalid PDF file",
+                "description": "Extracts every embedded image, or the images on the selected pages, in its native format.\nReturns a ZIP archive with the images and a manifest.json listing page, object id, dimensions, filter\nand the size of each image file.",
                 "consumes": [
                     "multipart/form-data",
                     "application/json"
                 ],
                 "produces": [
-                    "application/octet-stream"
+                    "application/zip"
                 ],
                 "tags": [
                     "PDF Operations"
                 ],
-                "summary": "Repair a PDF file",
+                "summary": "Extract embedded images",
                 "parameters": [
                     {
                         "type": "file",
-                        "description": "PDF file to repair",
+                        "description": "PDF file to extract images from",
                         "name": "file",
                         "in": "formData"
                     },
@@ -306,6 +531,1761 @@ const docTemplate = `{
                         "schema": {
                             "type": "object"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Page selection, defaults to all pages",
+                        "name": "pages",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/form/fields": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns every AcroForm field with its name, type, current value, options, pages and widget rects.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "List form fields",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/form/fill": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fills AcroForm fields from a JSON object of field name (or id) to value and optionally flattens the form,\nturning the fields into regular page content. Checkboxes take a bool, list boxes a list of strings.\nLocked fields are filled and stay locked.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Fill form fields",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF and values object",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "JSON object of field name to value",
+                        "name": "values",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Flatten the form after filling",
+                        "name": "flatten",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/images-to-pdf": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Creates a PDF with one JPEG, PNG, TIFF or WebP image per page, in the order given.\nWith page_size \"auto\" every page takes the size of its image at the given DPI plus the margin.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Convert images to PDF",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Images to convert (repeat the field for each image)",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64_images array",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Page size: A4 (default), A4L, Letter, Legal, ... or auto",
+                        "name": "page_size",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Page margin in points",
+                        "name": "margin",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "contain (default) scales the image into the page, original keeps its size at the given DPI",
+                        "name": "fit",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Image resolution in dots per inch (default 72)",
+                        "name": "dpi",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/info": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns page count, page sizes, version, encryption status, permissions, document properties,\nXMP presence, linearization, tagged status and attachment count.\nEncrypted files that need a password are reported with password_required instead of failing.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Inspect a PDF file",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file to inspect",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/jobs": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Queues an operation to run in the background and returns the job, whose status is polled at\n/v1/jobs/{id} and whose output is downloaded from /v1/jobs/{id}/result once completed. The request\nis the one of the operation, multipart form or JSON, with the operation name added as operation,\ne.g. encrypt, decrypt, repair, optimize or ocr. With callback_url, the job is posted there once\nfinished. The X-Timestamp header holds the Unix time it was sent at and X-Signature-256 holds\nsha256= and the hex HMAC-SHA256, keyed with WEBHOOK_SECRET, of the timestamp, a dot and the raw\nbody. Receivers check both and refuse timestamps more than a few minutes old. A job running\nlonger than its operation allows, or than JOB_TIMEOUT for operations without a limit, fails.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Jobs"
+                ],
+                "summary": "Create a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Operation to run, the path of its endpoint without /v1/",
+                        "name": "operation",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "URL the finished job is posted to, on a public host unless JOB_CALLBACK_NETWORKS allows it",
+                        "name": "callback_url",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "file",
+                        "description": "PDF file for the operation",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request of the operation with its operation name",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/jobs/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the status of a job: queued, running, completed or failed, with its progress in percent\nand, if it failed, the error. Jobs are found with the API key or in the session that created them.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Jobs"
+                ],
+                "summary": "Get a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/jobs/{id}/result": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the output of a completed job, as the operation itself would have responded. Results are\nfound with the API key or in the session that created the job.",
+                "produces": [
+                    "application/json",
+                    "application/pdf",
+                    "application/zip"
+                ],
+                "tags": [
+                    "Jobs"
+                ],
+                "summary": "Download a job result",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/merge": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Combines multiple PDF files into one, in the order given",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Merge PDF files",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF files to merge (repeat the field for each file)",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64_pdfs array",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/metadata": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Sets the title, author, subject, keywords and creator of the document info dictionary,\nplus any custom properties. An empty value clears the property, clear_all removes all of them first.\nThe producer and dates are always updated on write.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Set or clear document properties",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file to update",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Document title",
+                        "name": "title",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Document author",
+                        "name": "author",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Document subject",
+                        "name": "subject",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Document keywords",
+                        "name": "keywords",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Application that created the original document",
+                        "name": "creator",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "JSON object of custom property name to value",
+                        "name": "properties",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Remove all existing properties before setting new ones",
+                        "name": "clear_all",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/ocr": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Performs OCR on a PDF file with the configured provider, the Mistral OCR API or a local Tesseract.\nDocuments are shared with Mistral through S3, or sent inline when S3 is disabled or OCR_INLINE is set.\nPages that already have a text layer are not sent to OCR, their extracted text is returned instead,\nunless force_ocr selects them. Pages whose text can not be read are sent to OCR as well.\nWith output=pdf, the PDF itself is returned with the recognized words laid over the OCR pages\nas invisible text, which makes scanned pages searchable.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/pdf"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Perform OCR on a PDF file",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file to process",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format, json (default) or pdf for the PDF with an invisible text layer over the OCR pages, which needs tesseract",
+                        "name": "output",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pages sent to OCR even if they have a text layer, e.g. 1,3-5 or 1- for all",
+                        "name": "force_ocr",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "URL the signed result is posted to once done, which runs the request as a job and responds with it",
+                        "name": "callback_url",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "408": {
+                        "description": "Request Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/optimize": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Optimize a PDF file. A profile (lossless, web, ebook or screen) sets the image resolution and JPEG quality,\nimage_dpi and jpeg_quality override it. The original and optimized sizes in bytes are returned\nin the X-Original-Size and X-Optimized-Size headers. linearize writes a file viewers can show\nbefore it is fully downloaded. Optionally flattens form fields and annotations into the page content\nand strips annotations, JavaScript and open actions. print_ready turns on all of these.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Optimize a PDF file",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file to optimize",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "lossless (default), web (200 dpi, quality 85), ebook (150 dpi, quality 75) or screen (72 dpi, quality 50)",
+                        "name": "profile",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Downsample images drawn at a higher resolution to this many dpi",
+                        "name": "image_dpi",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Re-encode images as JPEG of this quality (1-100)",
+                        "name": "jpeg_quality",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Merge duplicate fonts, images and content streams, defaults to true",
+                        "name": "dedup",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Remove fonts, images and other resources no page uses, defaults to true for the web, ebook and screen profiles",
+                        "name": "remove_unused",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Draw form fields into the page content and remove the form",
+                        "name": "flatten_forms",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Draw all annotations, including form fields, into the page content",
+                        "name": "flatten_annotations",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Remove all annotations except form fields",
+                        "name": "remove_annotations",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Remove document, page, field and link JavaScript",
+                        "name": "remove_javascript",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Remove the open action and document level additional actions",
+                        "name": "remove_open_actions",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Flatten everything and remove all interactive elements",
+                        "name": "print_ready",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Write a linearized file (fast web view) that viewers can show before it is fully downloaded",
+                        "name": "linearize",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/pages": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Builds a new PDF from a page selection. \"extract\" keeps the selected pages, \"remove\" drops them.\n\"reorder\" moves the pages listed, each at most once, to the front in the order given, the other pages\nfollow in their order. \"duplicate\" keeps every page and inserts a copy after each selected page, one\ncopy per time the page is listed.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Extract, remove or reorder pages",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file to process",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "extract, remove, reorder or duplicate",
+                        "name": "operation",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Page selection, e.g. 1-3,5,even,!4,l",
+                        "name": "pages",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/pipeline": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Runs steps on a PDF file one after the other, each on the output of the one before, and returns the\noutput of the last. The file stays on the server in between. Steps are given as a JSON array of\noperation names or of {\"operation\": ..., \"options\": {...}} objects, whose options are those of the\noperation's route, or in a form as a comma separated list of names. The operations are repair, optimize,\nwatermark, rotate, pages, metadata, form/fill, encrypt, decrypt and reencrypt. pdf_password opens the\ninput, encrypt takes the new password as its password option, replacing any encryption of the input,\nand later steps open the file with it.\nLike the repair route, the repair step leaves files that need no repair as they are.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Run several operations on a PDF file in a row",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file to process",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF and steps array",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "JSON array of steps, or comma separated operation names, e.g. repair,optimize",
+                        "name": "steps",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "408": {
+                        "description": "Request Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/reencrypt": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Re-encrypts an encrypted PDF with a new user and/or owner password or permission set in one call,\nwithout the decrypted document leaving the server.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Change passwords or permissions of an encrypted PDF",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Encrypted PDF file",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Current user password",
+                        "name": "pdf_password",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Current owner password, defaults to pdf_password",
+                        "name": "owner_password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "New user password",
+                        "name": "new_user_password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "New owner password",
+                        "name": "new_owner_password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "New permissions, comma separated: print, print_high_quality, copy, modify, annotate, fill_forms, assemble, all or none",
+                        "name": "permissions",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/render": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Rasterizes the selected pages to PNG or JPEG at the given resolution, or scaled down to max_width pixels. Annotations with an appearance, such as form fields, are drawn on top of the page.\nReturns the image of a single page, or a ZIP archive with one image per page when several pages are selected.\nAt most 100 pages are rendered per request.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "image/png",
+                    "image/jpeg",
+                    "application/zip"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Render pages to images",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file to render",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Page selection, defaults to all pages, at most 100",
+                        "name": "pages",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Image format, png (default) or jpeg",
+                        "name": "format",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Resolution in dots per inch (default 150, max 600)",
+                        "name": "dpi",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum image width in pixels, lowers the resolution of wider pages",
+                        "name": "max_width",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "JPEG quality 1-100 (default 90)",
+                        "name": "quality",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "408": {
+                        "description": "Request Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/repair": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Repair a corrupt or invalid PDF file",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Repair a PDF file",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file to repair",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/rotate": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Rotates all or selected pages clockwise by 90, 180 or 270 degrees.\npage_angles maps page numbers to their own angle, e.g. {\"1\": 90, \"3\": 270}, and takes precedence over rotation.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Rotate pages",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file to rotate",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Rotation for the selected pages: 90, 180 or 270",
+                        "name": "rotation",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Page selection for rotation, defaults to all pages",
+                        "name": "pages",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "JSON object of page number to angle",
+                        "name": "page_angles",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/split": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Splits a PDF file every N pages or by explicit page ranges, and returns the parts as a ZIP archive",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Split a PDF file",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file to split",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Split every N pages (default 1)",
+                        "name": "span",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated page ranges, e.g. 1-3,4,5-10",
+                        "name": "ranges",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/text": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Extracts the embedded text layer of the selected pages, without OCR, so scanned pages come back empty. Text of annotations is left out.\nReturns a list of pages with their text as JSON, or with format=text the text of all pages as text/plain,\nseparated by form feeds. With layout, the text is spaced out to resemble the page.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/plain"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Extract text",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Page selection, defaults to all pages",
+                        "name": "pages",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format, json (default) or text",
+                        "name": "format",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Preserve the layout of the page",
+                        "name": "layout",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "408": {
+                        "description": "Request Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/watermark": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stamps text or a PNG/JPEG image onto all or selected pages.\nmode \"watermark\" puts it behind the page content, \"stamp\" on top of it.",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "PDF Operations"
+                ],
+                "summary": "Add a text or image watermark",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PDF file to watermark",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of an encrypted PDF",
+                        "name": "pdf_password",
+                        "in": "formData"
+                    },
+                    {
+                        "description": "JSON request with base64 PDF, base64_image for image watermarks",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "text or image",
+                        "name": "type",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "watermark (default) or stamp",
+                        "name": "mode",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Page selection, defaults to all pages",
+                        "name": "pages",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Text to stamp",
+                        "name": "text",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Font name, e.g. Helvetica, Times-Roman, Courier",
+                        "name": "font",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Font size in points",
+                        "name": "font_size",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Text color, e.g. #FF0000",
+                        "name": "color",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Opacity between 0 and 1",
+                        "name": "opacity",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Rotation in degrees, defaults to diagonal",
+                        "name": "rotation",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Anchor: tl, tc, tr, l, c, r, bl, bc, br",
+                        "name": "position",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Offset from the anchor in points, e.g. \\",
+                        "name": "offset",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Scale factor, e.g. 0.5 rel or 1 abs",
+                        "name": "scale",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "file",
+                        "description": "PNG or JPEG image to stamp",
+                        "name": "image",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
@@ -0,0 +1,96 @@
+// Package pdftest builds small PDF files for tests.
+package pdftest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Builder collects the objects of a PDF file, numbered from 1 in the order they are
+// added or reserved.
+type Builder struct {
+	objects []string
+}
+
+// Add adds the object obj, written as in a PDF file, and returns its number.
+func (b *Builder) Add(obj string) int {
+	b.objects = append(b.objects, obj)
+	return len(b.objects)
+}
+
+// Reserve returns the number of an object set later with Set, for objects referring
+// to each other.
+func (b *Builder) Reserve() int {
+	return b.Add("null")
+}
+
+// Set sets the reserved object nr to obj.
+func (b *Builder) Set(nr int, obj string) {
+	b.objects[nr-1] = obj
+}
+
+// Bytes returns the PDF file with the catalog root.
+func (b *Builder) Bytes(root int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(b.objects))
+	for i, obj := range b.objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(b.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(b.objects)+1, root, xref)
+
+	return buf.Bytes()
+}
+
+// Write writes the PDF file with the catalog root to a temporary directory of t and
+// returns its path.
+func (b *Builder) Write(t testing.TB, root int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.pdf")
+	if err := os.WriteFile(path, b.Bytes(root), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Stream returns a stream object with the entries dict, without Length, and content.
+func Stream(dict, content string) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(content), content)
+}
+
+// Document adds the page tree and catalog of pages, the page dicts without their
+// Type and Parent, and returns the number of the catalog.
+func (b *Builder) Document(pages ...string) int {
+	tree := b.Reserve()
+	kids := ""
+	for _, page := range pages {
+		kids += fmt.Sprintf("%d 0 R ", b.Add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R %s >>", tree, page)))
+	}
+	b.Set(tree, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(pages)))
+	return b.Add(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", tree))
+}
+
+// TextPDF writes a file of n pages of 200 by 200 points, each showing "Page" and its
+// number in Helvetica, and returns its path.
+func TextPDF(t testing.TB, n int) string {
+	t.Helper()
+	var b Builder
+	font := b.Add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	pages := make([]string, n)
+	for i := range pages {
+		content := b.Add(Stream("", fmt.Sprintf("BT /F1 24 Tf 40 90 Td (Page %d) Tj ET", i+1)))
+		pages[i] = fmt.Sprintf("/MediaBox [0 0 200 200] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R", font, content)
+	}
+	return b.Write(t, b.Document(pages...))
+}
@@ -15,7 +15,6 @@ import (
 	zlogsentry "github.com/archdx/zerolog-sentry"
 	"github.com/gofiber/storage/minio"
 	"github.com/ilyakaznacheev/cleanenv"
-	mo "github.com/minio/minio-go/v7"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -67,9 +66,6 @@ func main() {
 				AccessKeyID:     types.Config.S3.Key.Access,
 				SecretAccessKey: types.Config.S3.Key.Secret,
 			},
-			PutObjectOptions: mo.PutObjectOptions{
-				UserMetadata: map[string]string{"x-amz-acl": "public-read"}, // force to public access
-			},
 		})
 	}
 
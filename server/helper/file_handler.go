@@ -1,12 +1,14 @@
 package helper
 
 import (
+	"crypto/rand"
 	"encoding/base64"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/gofiber/fiber/v3"
@@ -22,9 +24,17 @@ type pdfRequest struct {
 	Cleanup    func()
 }
 
+type multiPDFRequest struct {
+	InputPaths []string
+	OutputPath string
+	OutputName string
+	Cleanup    func()
+}
+
 type PDFProcessOptions struct {
 	RequirePassword bool
 	OutputPrefix    string
+	OutputExt       string // replaces the input extension, e.g. ".zip"
 }
 
 type pdfError struct {
@@ -100,27 +110,8 @@ func ProcessPDFRequest(ctx fiber.Ctx, opts PDFProcessOptions) (*pdfRequest, *pdf
 		password = request.Password
 		tempPath = filepath.Join("/tmp", "base64-"+filename)
 
-		// Create a temp file
-		tmpFile, err := os.Create(tempPath)
-		if err != nil {
-			log.Error().Err(err).Caller().Send()
-			return nil, newPDFError(fiber.StatusInternalServerError, "Failed to create temporary file")
-		}
-
-		// Use a decoder that writes directly to file
-		decoder := base64.NewDecoder(base64.StdEncoding, strings.NewReader(request.Base64))
-		written, err := io.Copy(tmpFile, decoder)
-		tmpFile.Close() // Close immediately after writing
-
-		if err != nil {
-			os.Remove(tempPath) // Clean up on error
-			log.Error().Err(err).Caller().Send()
-			return nil, newPDFError(fiber.StatusBadRequest, "Invalid base64 PDF data")
-		}
-
-		if written == 0 {
-			os.Remove(tempPath) // Clean up on error
-			return nil, newPDFError(fiber.StatusBadRequest, "Decoded PDF data cannot be empty")
+		if err := decodeBase64File(tempPath, request.Base64, "PDF"); err != nil {
+			return nil, err
 		}
 
 		cleanup = func() {
@@ -137,18 +128,207 @@ func ProcessPDFRequest(ctx fiber.Ctx, opts PDFProcessOptions) (*pdfRequest, *pdf
 	}
 
 	// Generate output filename and path
+	outputFilename, outputPath := outputFile(opts, filename)
+
+	return &pdfRequest{
+		InputPath:  tempPath,
+		OutputPath: outputPath,
+		OutputName: outputFilename,
+		Password:   password,
+		Cleanup:    expandCleanup(cleanup, outputPath),
+	}, nil
+}
+
+// ProcessMultiPDFRequest is the multi-file variant of ProcessPDFRequest. It accepts
+// several "file" parts in a multipart form, or a "base64_pdfs" array in a JSON body,
+// and keeps them in the order given.
+func ProcessMultiPDFRequest(ctx fiber.Ctx, opts PDFProcessOptions) (*multiPDFRequest, *pdfError) {
+	return processMultiFileRequest(ctx, opts, pdfUpload)
+}
+
+// ProcessImageRequest works like ProcessMultiPDFRequest but accepts JPEG, PNG, TIFF and WebP
+// images, as "file" parts or a "base64_images" array in a JSON body.
+func ProcessImageRequest(ctx fiber.Ctx, opts PDFProcessOptions) (*multiPDFRequest, *pdfError) {
+	return processMultiFileRequest(ctx, opts, imageUpload)
+}
+
+// uploadKind describes the files a multi-file request accepts.
+type uploadKind struct {
+	label, title string // used in error messages, title starts a sentence
+	images       bool   // read "base64_images" instead of "base64_pdfs" from JSON
+	contentTypes []string
+}
+
+var (
+	pdfUpload   = uploadKind{label: "PDF", title: "PDF", contentTypes: []string{"application/pdf"}}
+	imageUpload = uploadKind{label: "image", title: "Image", images: true, contentTypes: []string{"image/jpeg", "image/png", "image/tiff", "image/webp"}}
+)
+
+func processMultiFileRequest(ctx fiber.Ctx, opts PDFProcessOptions, kind uploadKind) (*multiPDFRequest, *pdfError) {
+	var (
+		filename    string
+		inputPaths  []string
+		contentType = ctx.Get("Content-Type")
+	)
+
+	tempDir, err := os.MkdirTemp("/tmp", "upload-*")
+	if err != nil {
+		log.Error().Err(err).Caller().Send()
+		return nil, newPDFError(fiber.StatusInternalServerError, "Failed to create temporary directory")
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			log.Warn().Err(err).Msg("failed to remove temporary directory")
+		}
+	}
+
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		// Handle multipart/form-data
+		form, err := ctx.MultipartForm()
+		if err != nil {
+			cleanup()
+			log.Error().Err(err).Caller().Send()
+			return nil, newPDFError(fiber.StatusBadRequest, "Invalid multipart form")
+		}
+
+		files := form.File["file"]
+		if len(files) == 0 {
+			cleanup()
+			return nil, newPDFError(fiber.StatusBadRequest, "No file uploaded")
+		}
+
+		for i, file := range files {
+			if file.Size == 0 {
+				cleanup()
+				return nil, newPDFError(fiber.StatusBadRequest, fmt.Sprintf("File %s cannot be empty", file.Filename))
+			}
+
+			if !slices.Contains(kind.contentTypes, file.Header.Get("Content-Type")) {
+				cleanup()
+				if kind.images {
+					return nil, newPDFError(fiber.StatusBadRequest, "Invalid file type. Only JPEG, PNG, TIFF and WebP images are allowed")
+				}
+				return nil, newPDFError(fiber.StatusBadRequest, "Invalid file type. Only PDF files are allowed")
+			}
+
+			tempPath := filepath.Join(tempDir, fmt.Sprintf("%03d-%s", i, filepath.Base(file.Filename)))
+			if err := ctx.SaveFile(file, tempPath); err != nil {
+				cleanup()
+				log.Error().Err(err).Caller().Send()
+				return nil, newPDFError(fiber.StatusInternalServerError, "Failed to save uploaded file")
+			}
+			inputPaths = append(inputPaths, tempPath)
+		}
+
+		filename = files[0].Filename
+
+	} else {
+		// Handle JSON with base64
+		var request struct {
+			Filename string   `json:"filename"`
+			PDFs     []string `json:"base64_pdfs"`
+			Images   []string `json:"base64_images"`
+		}
+
+		if err := ctx.Bind().Body(&request); err != nil {
+			cleanup()
+			return nil, newPDFError(fiber.StatusBadRequest, "Invalid JSON body")
+		}
+
+		files := request.PDFs
+		if kind.images {
+			files = request.Images
+		}
+
+		if len(files) == 0 {
+			cleanup()
+			return nil, newPDFError(fiber.StatusBadRequest, fmt.Sprintf("%s data cannot be empty", kind.title))
+		}
+
+		for i, data := range files {
+			if data == "" {
+				cleanup()
+				return nil, newPDFError(fiber.StatusBadRequest, fmt.Sprintf("%s data cannot be empty", kind.title))
+			}
+
+			tempPath := filepath.Join(tempDir, fmt.Sprintf("%03d", i))
+			if err := decodeBase64File(tempPath, data, kind.label); err != nil {
+				cleanup()
+				return nil, err
+			}
+			inputPaths = append(inputPaths, tempPath)
+		}
+
+		filename = request.Filename
+		if filename == "" {
+			filename = "document.pdf"
+		}
+	}
+
+	outputFilename, outputPath := outputFile(opts, filename)
+
+	return &multiPDFRequest{
+		InputPaths: inputPaths,
+		OutputPath: outputPath,
+		OutputName: outputFilename,
+		Cleanup:    expandCleanup(cleanup, outputPath),
+	}, nil
+}
+
+// decodeBase64File decodes base64 data straight into a new file at path.
+func decodeBase64File(path, data, label string) *pdfError {
+	tmpFile, err := os.Create(path)
+	if err != nil {
+		log.Error().Err(err).Caller().Send()
+		return newPDFError(fiber.StatusInternalServerError, "Failed to create temporary file")
+	}
+
+	// Use a decoder that writes directly to file
+	decoder := base64.NewDecoder(base64.StdEncoding, strings.NewReader(data))
+	written, err := io.Copy(tmpFile, decoder)
+	tmpFile.Close() // Close immediately after writing
+
+	if err != nil {
+		os.Remove(path) // Clean up on error
+		log.Error().Err(err).Caller().Send()
+		return newPDFError(fiber.StatusBadRequest, fmt.Sprintf("Invalid base64 %s data", label))
+	}
+
+	if written == 0 {
+		os.Remove(path) // Clean up on error
+		return newPDFError(fiber.StatusBadRequest, fmt.Sprintf("Decoded %s data cannot be empty", label))
+	}
+
+	return nil
+}
+
+// outputFile builds the slugged output filename and its path in /tmp.
+func outputFile(opts PDFProcessOptions, filename string) (string, string) {
 	ext := filepath.Ext(filename)
 	nameWithoutExt := strings.TrimSuffix(filename, ext)
-	outputPrefix := opts.OutputPrefix
-	if outputPrefix == "" {
-		outputPrefix = "processed"
+	prefix := opts.OutputPrefix
+	if prefix == "" {
+		prefix = "processed"
+	}
+	if opts.OutputExt != "" {
+		ext = opts.OutputExt
 	}
 
-	outputFilename := fmt.Sprintf("%s_%s%s", outputPrefix, slug.MakeLang(nameWithoutExt, "en"), ext)
-	outputPath := filepath.Join("/tmp", outputFilename)
+	outputFilename := fmt.Sprintf("%s_%s%s", prefix, slug.MakeLang(nameWithoutExt, "en"), ext)
+	return outputFilename, uniqueOutputPath(outputFilename)
+}
+
+// uniqueOutputPath returns a path in /tmp for the output file name, unique per request.
+// Requests for files of the same name would otherwise write the same path, and
+// ctx.Download, which serves files through a cache keyed by path for 10 seconds, could
+// answer one request with the output of another.
+func uniqueOutputPath(name string) string {
+	return filepath.Join("/tmp", rand.Text()+"_"+name)
+}
 
-	// Expand cleanup to handle both files
-	expandedCleanup := func() {
+// expandCleanup extends cleanup to also remove the output file.
+func expandCleanup(cleanup func(), outputPath string) func() {
+	return func() {
 		runtime.GC()
 		cleanup()
 
@@ -163,12 +343,4 @@ func ProcessPDFRequest(ctx fiber.Ctx, opts PDFProcessOptions) (*pdfRequest, *pdf
 			log.Error().Err(err).Msgf("Error checking if output file %s exists", outputPath)
 		}
 	}
-
-	return &pdfRequest{
-		InputPath:  tempPath,
-		OutputPath: outputPath,
-		OutputName: outputFilename,
-		Password:   password,
-		Cleanup:    expandedCleanup,
-	}, nil
 }
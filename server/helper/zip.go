@@ -0,0 +1,47 @@
+package helper
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ZipFiles writes files into a new ZIP archive at zipPath, each stored under its base name.
+func ZipFiles(zipPath string, files []string) error {
+	out, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	for _, file := range files {
+		if err := addFileToZip(zw, file); err != nil {
+			zw.Close()
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+func addFileToZip(zw *zip.Writer, file string) error {
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	w, err := zw.Create(filepath.Base(file))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, in)
+	return err
+}
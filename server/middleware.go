@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"pdftool/types"
+	"runtime/debug"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
@@ -69,22 +70,32 @@ func timeoutMiddleware(timeout time.Duration) fiber.Handler {
         // Create a channel to signal when the request is complete
         done := make(chan struct{})
 
-        // Create a context with a timeout
+        // Create a context with a timeout, handlers stop long work once it is done
         ctx, cancel := context.WithTimeout(c.Context(), timeout)
         defer cancel()
+        c.SetContext(ctx)
 
+        // The recover middleware does not reach this goroutine, a panic is recovered here
+        var panicErr error
         go func() {
+            defer close(done)
+            defer func() {
+                if r := recover(); r != nil {
+                    log.Error().Interface("panic", r).Str("stack", string(debug.Stack())).Msg("Panic in handler")
+                    panicErr = fiber.ErrInternalServerError
+                }
+            }()
+
             // Call the next handler
             if err := c.Next(); err != nil {
                 log.Error().Err(err).Msg("Error in handler")
             }
-            close(done)
         }()
 
         // Wait for either the request to complete or the timeout to expire
         select {
         case <-done:
-            return nil
+            return panicErr
         case <-ctx.Done():
             return fiber.NewError(fiber.StatusRequestTimeout, "Request timeout")
         }
@@ -0,0 +1,165 @@
+// Package render renders PDF pages to images and extracts their text with MuPDF. Each
+// document is opened by a worker process of its own, the running executable started
+// again in worker mode, so a page that takes too long is stopped by killing the worker
+// and a crash of MuPDF ends the worker only.
+package render
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// workerEnv is the environment variable starting the executable as a worker.
+const workerEnv = "PDFTOOL_RENDER_WORKER"
+
+// ErrClosed is returned by documents whose worker stopped, e.g. after a cancelled call.
+var ErrClosed = errors.New("render: document closed")
+
+// workerRequest is a call of the worker, one JSON object per line on its stdin.
+type workerRequest struct {
+	Op      string  `json:"op"`
+	File    string  `json:"file,omitempty"`
+	Page    int     `json:"page,omitempty"`
+	DPI     float64 `json:"dpi,omitempty"`
+	Quality int     `json:"quality,omitempty"`
+	Layout  bool    `json:"layout,omitempty"`
+}
+
+// workerResponse is the answer of the worker to a request, on its stdout.
+type workerResponse struct {
+	Pages  int    `json:"pages,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Text   string `json:"text,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Document is a PDF file opened by a worker. Its methods are not safe for concurrent use.
+type Document struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *json.Decoder
+	stderr strings.Builder
+	pages  int
+}
+
+// Open starts a worker opening file, which must not need a password.
+func Open(ctx context.Context, file string) (*Document, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Document{cmd: exec.Command(executable)}
+	d.cmd.Env = append(os.Environ(), workerEnv+"=1")
+	d.cmd.Stderr = &d.stderr
+	if d.stdin, err = d.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	stdout, err := d.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	d.stdout = json.NewDecoder(stdout)
+	if err := d.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start render worker: %v", err)
+	}
+
+	resp, err := d.call(ctx, workerRequest{Op: "open", File: file})
+	if err != nil {
+		d.Close()
+		return nil, err
+	}
+	d.pages = resp.Pages
+	return d, nil
+}
+
+// PageCount returns the number of pages of the document.
+func (d *Document) PageCount() int {
+	return d.pages
+}
+
+// RenderPage renders page pageNr with dpi pixels per inch to file, as JPEG of quality if
+// the file name ends in .jpg and as PNG otherwise, and returns the size of the image.
+// Only the page content is drawn, annotations are left out. Once ctx is done, the worker
+// is stopped and the document closed.
+func (d *Document) RenderPage(ctx context.Context, pageNr int, dpi float64, file string, quality int) (int, int, error) {
+	resp, err := d.call(ctx, workerRequest{Op: "render", Page: pageNr, DPI: dpi, File: file, Quality: quality})
+	if err != nil {
+		return 0, 0, err
+	}
+	return resp.Width, resp.Height, nil
+}
+
+// PageText returns the text of page pageNr in reading order, or placed on a grid of
+// characters resembling the page if layout is set. Annotations are left out. Once ctx is
+// done, the worker is stopped and the document closed.
+func (d *Document) PageText(ctx context.Context, pageNr int, layout bool) (string, error) {
+	resp, err := d.call(ctx, workerRequest{Op: "text", Page: pageNr, Layout: layout})
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
+// Close stops the worker.
+func (d *Document) Close() error {
+	if d.stdin == nil {
+		return nil
+	}
+	d.stdin.Close()
+	d.stdin = nil
+	d.cmd.Process.Kill() //nolint:errcheck
+	d.cmd.Wait()         //nolint:errcheck
+	return nil
+}
+
+// call sends req to the worker and waits for its response, or stops the worker once ctx
+// is done. A worker that exits, e.g. because MuPDF crashed, closes the document.
+func (d *Document) call(ctx context.Context, req workerRequest) (*workerResponse, error) {
+	if d.stdin == nil {
+		return nil, ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		d.Close()
+		return nil, err
+	}
+
+	type result struct {
+		resp workerResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	stdin := d.stdin
+	go func() {
+		var r result
+		if r.err = json.NewEncoder(stdin).Encode(req); r.err == nil {
+			r.err = d.stdout.Decode(&r.resp)
+		}
+		done <- r
+	}()
+
+	select {
+	case <-ctx.Done():
+		d.cmd.Process.Kill() //nolint:errcheck
+		<-done
+		d.Close()
+		return nil, ctx.Err()
+	case r := <-done:
+		if r.err != nil {
+			d.Close()
+			return nil, fmt.Errorf("render worker failed: %v: %s", r.err, strings.TrimSpace(d.stderr.String()))
+		}
+		if r.resp.Error != "" {
+			return nil, errors.New(r.resp.Error)
+		}
+		return &r.resp, nil
+	}
+}
@@ -0,0 +1,199 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"pdftool/internal/pdftest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderPage(t *testing.T) {
+	var b pdftest.Builder
+	content := b.Add(pdftest.Stream("", "1 0 0 rg 0 0 50 50 re f"))
+	page := fmt.Sprintf("/MediaBox [0 0 100 50] /Contents %d 0 R", content)
+	file := b.Write(t, b.Document(page, page+" /Rotate 90"))
+
+	doc, err := Open(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+	if doc.PageCount() != 2 {
+		t.Errorf("got %d pages, want 2", doc.PageCount())
+	}
+
+	dir := t.TempDir()
+	tests := []struct {
+		pageNr        int
+		file          string
+		width, height int
+		// A pixel in the red square and one outside of it
+		red, white image.Point
+	}{
+		{1, "page.png", 200, 100, image.Pt(50, 50), image.Pt(150, 50)},
+		{1, "page.jpg", 200, 100, image.Pt(50, 50), image.Pt(150, 50)},
+		{2, "rotated.png", 100, 200, image.Pt(50, 50), image.Pt(50, 150)},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.file)
+		w, h, err := doc.RenderPage(context.Background(), tt.pageNr, 144, path, 90)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.file, err)
+		}
+		if w != tt.width || h != tt.height {
+			t.Errorf("%s: got size %dx%d, want %dx%d", tt.file, w, h, tt.width, tt.height)
+		}
+
+		img := readTestImage(t, path)
+		if img.Bounds().Dx() != w || img.Bounds().Dy() != h {
+			t.Errorf("%s: image is %v, reported %dx%d", tt.file, img.Bounds(), w, h)
+		}
+		if c := img.At(tt.red.X, tt.red.Y); !near(c, color.RGBA{255, 0, 0, 255}) {
+			t.Errorf("%s: got %v at %v, want red", tt.file, c, tt.red)
+		}
+		if c := img.At(tt.white.X, tt.white.Y); !near(c, color.RGBA{255, 255, 255, 255}) {
+			t.Errorf("%s: got %v at %v, want white", tt.file, c, tt.white)
+		}
+	}
+
+	if _, _, err := doc.RenderPage(context.Background(), 3, 72, filepath.Join(dir, "missing.png"), 0); err == nil {
+		t.Error("rendering a missing page succeeded")
+	}
+}
+
+func TestPageText(t *testing.T) {
+	var b pdftest.Builder
+	font := b.Add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	content := b.Add(pdftest.Stream("", strings.Join([]string{
+		"BT /F1 10 Tf 20 180 Td (First line) Tj ET",
+		"BT /F1 10 Tf 20 166 Td (Second line) Tj ET",
+		"BT /F1 10 Tf 20 100 Td (Left) Tj ET",
+		"BT /F1 10 Tf 120 100 Td (Right) Tj ET",
+	}, "\n")))
+	file := b.Write(t, b.Document(fmt.Sprintf("/MediaBox [0 0 200 200] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R", font, content)))
+
+	doc, err := Open(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+
+	text, err := doc.PageText(context.Background(), 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"First line\n", "Second line\n", "Left", "Right"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("got text %q, want it to contain %q", text, want)
+		}
+	}
+	if strings.HasPrefix(text, "\n") || strings.Contains(text, "\n\n\n") {
+		t.Errorf("got text %q, want one blank line between blocks at most", text)
+	}
+
+	text, err = doc.PageText(context.Background(), 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) < 4 || lines[0] != "First line" || lines[1] != "Second line" {
+		t.Fatalf("got layout %q", text)
+	}
+	// The gap before the last row is kept, and its columns 100pt or 20 cells apart
+	last := lines[len(lines)-2]
+	if lines[2] != "" || !strings.HasPrefix(last, "Left ") || strings.Index(last, "Right") != 20 {
+		t.Errorf("got layout %q", text)
+	}
+}
+
+func TestRenderPageCancel(t *testing.T) {
+	// Many long strokes take MuPDF a while to draw
+	var sb strings.Builder
+	for i := range 20000 {
+		fmt.Fprintf(&sb, "%d 0 m %d 1000 l\n", i%1000, (i*7)%1000)
+	}
+	sb.WriteString("S")
+	var b pdftest.Builder
+	content := b.Add(pdftest.Stream("", sb.String()))
+	file := b.Write(t, b.Document(fmt.Sprintf("/MediaBox [0 0 1000 1000] /Contents %d 0 R", content)))
+
+	doc, err := Open(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err = doc.RenderPage(ctx, 1, 600, filepath.Join(t.TempDir(), "page.png"), 0)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want the deadline", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("rendering stopped after %v", d)
+	}
+	if doc.cmd.ProcessState == nil {
+		t.Error("worker still running")
+	}
+	if _, _, err := doc.RenderPage(context.Background(), 1, 72, filepath.Join(t.TempDir(), "page.png"), 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("got error %v after cancelling, want %v", err, ErrClosed)
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "invalid.pdf")
+	if err := os.WriteFile(file, []byte("not a PDF"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if doc, err := Open(context.Background(), file); err == nil {
+		doc.Close()
+		t.Error("opening an invalid file succeeded")
+	}
+}
+
+func TestLayoutText(t *testing.T) {
+	page := `<div id="page0" style="width:200.0pt;height:200.0pt">
+<p style="top:10.0pt;left:20.0pt;line-height:10.0pt"><span style="font-size:10.0pt">Title &amp; more</span></p>
+<p style="top:60.0pt;left:20.0pt;line-height:10.0pt"><b><span style="font-size:10.0pt">a</span></b></p>
+<p style="top:60.5pt;left:70.0pt;line-height:10.0pt"><span style="font-size:10.0pt">b</span></p>
+</div>`
+	want := "Title & more\n\n\n\na         b\n"
+	if got := layoutText(htmlTextLines(page)); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func readTestImage(t *testing.T, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// near reports whether c is within a small distance of want in every channel, as JPEG
+// shifts colors a little.
+func near(c color.Color, want color.RGBA) bool {
+	r, g, b, _ := c.RGBA()
+	d := func(v uint32, w uint8) bool {
+		diff := int(v>>8) - int(w)
+		return diff > -24 && diff < 24
+	}
+	return d(r, want.R) && d(g, want.G) && d(b, want.B)
+}
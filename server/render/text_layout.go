@@ -0,0 +1,127 @@
+package render
+
+import (
+	"html"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Layout thresholds, relative to the font size.
+const (
+	// Lines whose tops are closer than this are on the same row
+	lineTolerance = 0.5
+	// Characters are taken to be this wide on average
+	charWidth = 0.5
+	// Layout keeps at most this many blank lines between two rows of text
+	maxBlankLines = 3
+)
+
+// textLine is a line of MuPDF's structured text, positioned in points from the top left
+// of the page, size being its line height.
+type textLine struct {
+	text            string
+	top, left, size float64
+}
+
+var (
+	htmlLine = regexp.MustCompile(`<p style="top:([-\d.]+)pt;left:([-\d.]+)pt;line-height:([-\d.]+)pt">(.*?)</p>`)
+	htmlTag  = regexp.MustCompile(`<[^>]*>`)
+)
+
+// htmlTextLines returns the lines of a page printed as HTML by MuPDF, one paragraph with
+// the position of the line each.
+func htmlTextLines(page string) []textLine {
+	var lines []textLine
+	for _, m := range htmlLine.FindAllStringSubmatch(page, -1) {
+		top, err1 := strconv.ParseFloat(m[1], 64)
+		left, err2 := strconv.ParseFloat(m[2], 64)
+		size, err3 := strconv.ParseFloat(m[3], 64)
+		text := html.UnescapeString(htmlTag.ReplaceAllString(m[4], ""))
+		if err1 != nil || err2 != nil || err3 != nil || size <= 0 || strings.TrimSpace(text) == "" {
+			continue
+		}
+		lines = append(lines, textLine{text: text, top: top, left: left, size: size})
+	}
+	return lines
+}
+
+// layoutText places lines on a grid of characters, keeping their horizontal positions
+// and, up to maxBlankLines, the vertical space between them.
+func layoutText(lines []textLine) string {
+	if len(lines) == 0 {
+		return ""
+	}
+
+	// Rows are as high as the median line, a cell as wide as its average character
+	sizes := make([]float64, len(lines))
+	minLeft := math.Inf(1)
+	for i, line := range lines {
+		sizes[i] = line.size
+		minLeft = math.Min(minLeft, line.left)
+	}
+	slices.Sort(sizes)
+	lineHeight := sizes[len(sizes)/2]
+	cell := math.Max(lineHeight*charWidth, 1)
+
+	lines = slices.Clone(lines)
+	slices.SortStableFunc(lines, func(a, b textLine) int {
+		switch {
+		case a.top < b.top:
+			return -1
+		case a.top > b.top:
+			return 1
+		}
+		return 0
+	})
+
+	// Lines close enough to the first of a row join it, then the row is filled from
+	// left to right
+	var rows [][]textLine
+	for _, line := range lines {
+		if n := len(rows); n > 0 && line.top-rows[n-1][0].top <= lineTolerance*line.size {
+			rows[n-1] = append(rows[n-1], line)
+		} else {
+			rows = append(rows, []textLine{line})
+		}
+	}
+
+	var sb strings.Builder
+	var row []rune
+	for i, lines := range rows {
+		if i > 0 {
+			blank := int(math.Round((lines[0].top-rows[i-1][0].top)/(lineHeight*1.2))) - 1
+			for range min(max(blank, 0), maxBlankLines) {
+				sb.WriteByte('\n')
+			}
+		}
+
+		slices.SortStableFunc(lines, func(a, b textLine) int {
+			switch {
+			case a.left < b.left:
+				return -1
+			case a.left > b.left:
+				return 1
+			}
+			return 0
+		})
+
+		row = row[:0]
+		for _, line := range lines {
+			// Lines start in the cell of their position, or past the text before them
+			col := int(math.Round((line.left - minLeft) / cell))
+			if len(row) > 0 {
+				col = max(col, len(row)+1)
+			}
+			for len(row) < col {
+				row = append(row, ' ')
+			}
+			row = append(row, []rune(line.text)...)
+		}
+		sb.WriteString(strings.TrimRight(string(row), " "))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
@@ -0,0 +1,164 @@
+package render
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/goccy/go-json"
+)
+
+// Limits of a worker: the address space it may take, and the memory MuPDF keeps fonts
+// and images cached in.
+const (
+	workerMemoryLimit = 4 << 30
+	workerStoreSize   = 256 << 20
+)
+
+// A worker runs before anything else of the executable once started in worker mode, in
+// tests as well, and exits once its stdin is closed.
+func init() {
+	if os.Getenv(workerEnv) == "" {
+		return
+	}
+
+	limit := syscall.Rlimit{Cur: workerMemoryLimit, Max: workerMemoryLimit}
+	if err := syscall.Setrlimit(syscall.RLIMIT_AS, &limit); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to limit memory: %v\n", err)
+	}
+	fitz.MaxStore = workerStoreSize
+
+	if err := serveWorker(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// serveWorker answers the requests read from r on w, one at a time.
+func serveWorker(r io.Reader, w io.Writer) error {
+	in := bufio.NewReader(r)
+	out := json.NewEncoder(w)
+
+	var doc *fitz.Document
+	defer func() {
+		if doc != nil {
+			doc.Close()
+		}
+	}()
+
+	for {
+		line, err := in.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req workerRequest
+		var resp workerResponse
+		if err := json.Unmarshal(line, &req); err != nil {
+			return err
+		}
+
+		switch {
+		case req.Op == "open" && doc == nil:
+			if doc, err = fitz.New(req.File); err == nil {
+				resp.Pages = doc.NumPage()
+			}
+		case doc == nil:
+			err = errors.New("no document open")
+		case req.Page < 1 || req.Page > doc.NumPage():
+			err = fmt.Errorf("page %d not found", req.Page)
+		case req.Op == "render":
+			resp.Width, resp.Height, err = renderPage(doc, req)
+		case req.Op == "text":
+			resp.Text, err = pageText(doc, req.Page, req.Layout)
+		default:
+			err = fmt.Errorf("unknown request %q", req.Op)
+		}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+
+		if err := out.Encode(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// renderPage renders the page of req and writes its image to req.File.
+func renderPage(doc *fitz.Document, req workerRequest) (int, int, error) {
+	img, err := doc.ImageDPI(req.Page-1, req.DPI)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to render page %d: %v", req.Page, err)
+	}
+
+	if err := writeImage(img, req.File, req.Quality); err != nil {
+		return 0, 0, err
+	}
+	return img.Bounds().Dx(), img.Bounds().Dy(), nil
+}
+
+func writeImage(img image.Image, file string, quality int) error {
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if filepath.Ext(file) == ".jpg" {
+		err = jpeg.Encode(out, img, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(out, img)
+	}
+	if err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// pageText returns the text of page pageNr, with the lines of MuPDF's structured text or
+// laid out from their positions.
+func pageText(doc *fitz.Document, pageNr int, layout bool) (string, error) {
+	if layout {
+		html, err := doc.HTML(pageNr-1, false)
+		if err != nil {
+			return "", fmt.Errorf("failed to read text of page %d: %v", pageNr, err)
+		}
+		return layoutText(htmlTextLines(html)), nil
+	}
+
+	text, err := doc.Text(pageNr - 1)
+	if err != nil {
+		return "", fmt.Errorf("failed to read text of page %d: %v", pageNr, err)
+	}
+	// MuPDF ends each block with a blank line, of which one is kept between blocks
+	var sb strings.Builder
+	blank := false
+	for line := range strings.SplitSeq(text, "\n") {
+		line = strings.TrimRight(line, " ")
+		if line == "" {
+			blank = sb.Len() > 0
+			continue
+		}
+		if blank {
+			sb.WriteByte('\n')
+			blank = false
+		}
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	return sb.String(), nil
+}
@@ -33,11 +33,47 @@ func router(app *fiber.App) {
 
 	// API
 	v1 := app.Group("/v1", authMiddleware())
-	v1.Post("/encrypt", timeoutMiddleware(2*time.Minute), routes.Encrypt)
-	v1.Post("/decrypt", timeoutMiddleware(2*time.Minute), routes.Decrypt)
-	v1.Post("/repair", timeoutMiddleware(2*time.Minute), routes.Repair)
-	v1.Post("/optimize", routes.Optimize)
-	if types.Config.S3.Enable {
-		v1.Post("/ocr", timeoutMiddleware(20*time.Minute), routes.OCR)
+
+	// Operations, also run in the background as jobs
+	operations := map[string]routes.JobOperation{}
+	operation := func(name string, timeout time.Duration, handler fiber.Handler) {
+		if timeout > 0 {
+			v1.Post("/"+name, timeoutMiddleware(timeout), handler)
+		} else {
+			v1.Post("/"+name, handler)
+		}
+		operations[name] = routes.JobOperation{Handler: handler, Timeout: timeout}
+	}
+	operation("encrypt", 2*time.Minute, routes.Encrypt)
+	operation("decrypt", 2*time.Minute, routes.Decrypt)
+	operation("reencrypt", 2*time.Minute, routes.Reencrypt)
+	operation("repair", 2*time.Minute, routes.Repair)
+	operation("optimize", 0, routes.Optimize)
+	operation("merge", 2*time.Minute, routes.Merge)
+	operation("split", 2*time.Minute, routes.Split)
+	operation("pages", 2*time.Minute, routes.Pages)
+	operation("rotate", 2*time.Minute, routes.Rotate)
+	operation("watermark", 2*time.Minute, routes.Watermark)
+	operation("images-to-pdf", 2*time.Minute, routes.ImagesToPDF)
+	operation("extract-images", 2*time.Minute, routes.ExtractImages)
+	operation("render", 2*time.Minute, routes.Render)
+	operation("text", 2*time.Minute, routes.Text)
+	operation("info", 2*time.Minute, routes.Info)
+	operation("metadata", 2*time.Minute, routes.Metadata)
+	operation("attachments/list", 2*time.Minute, routes.ListAttachments)
+	operation("attachments/add", 2*time.Minute, routes.AddAttachments)
+	operation("attachments/extract", 2*time.Minute, routes.ExtractAttachments)
+	operation("attachments/remove", 2*time.Minute, routes.RemoveAttachments)
+	operation("form/fields", 2*time.Minute, routes.FormFields)
+	operation("form/fill", 2*time.Minute, routes.FormFill)
+	operation("pipeline", 10*time.Minute, routes.Pipeline)
+	if routes.OCREnabled() {
+		operation("ocr", 20*time.Minute, routes.OCR)
 	}
+
+	// Jobs
+	routes.StartJobs(app.Config(), operations)
+	v1.Post("/jobs", routes.CreateJob)
+	v1.Get("/jobs/:id", routes.JobStatus)
+	v1.Get("/jobs/:id/result", routes.JobResult)
 }
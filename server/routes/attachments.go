@@ -0,0 +1,685 @@
+package routes
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"pdftool/server/helper"
+	"pdftool/types"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	pdftypes "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/rs/zerolog/log"
+)
+
+// attachmentInfo is one entry of the /v1/attachments/list response.
+type attachmentInfo struct {
+	ID          string     `json:"id"`
+	FileName    string     `json:"filename"`
+	Description string     `json:"description"`
+	Modified    *time.Time `json:"modified,omitempty"`
+	Size        int        `json:"size"`
+}
+
+// attachmentUpload is a file to embed, uploaded as an "attachment" form file or as base64 in JSON.
+type attachmentUpload struct {
+	FileName    string `json:"filename"`
+	Base64      string `json:"base64"`
+	Description string `json:"description"`
+	data        []byte
+}
+
+// afRelationships are the valid AFRelationship values of an associated file (PDF 2.0, PDF/A-3).
+var afRelationships = []string{"Source", "Data", "Alternative", "Supplement", "EncryptedPayload", "FormData", "Schema", "Unspecified"}
+
+// @Summary List embedded files
+// @Description Lists the file attachments embedded in a PDF with their name, description, modification time and size.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file"
+// @Param request body object false "JSON request with base64 PDF"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Success 200 {object} types.Response
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/attachments/list [post]
+func ListAttachments(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "attachments",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	pdfCtx, readErr := readAttachmentsContext(result.InputPath, result.Password, model.LISTATTACHMENTS)
+	if readErr != nil {
+		log.Error().Err(readErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			"File is invalid, corrupted or the password is wrong.",
+		)
+	}
+
+	list, listErr := listAttachments(pdfCtx)
+	if listErr != nil {
+		log.Error().Err(listErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusInternalServerError,
+			helper.TransformPDFCPUErrorToResponse(listErr),
+		)
+	}
+
+	return ctx.JSON(types.Response{
+		Error: false,
+		Data:  list,
+	})
+}
+
+// @Summary Embed files
+// @Description Embeds one or more files into a PDF. With relationship set, the files are also registered as
+// @Description associated files of the document, as required for ZUGFeRD/Factur-X invoices (e.g. Alternative or Data).
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce octet-stream
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file"
+// @Param request body object false "JSON request with base64 PDF and attachments array of {filename, base64, description}"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Param attachment formData file false "File to embed (repeat the field for each file)"
+// @Param description formData string false "Description, repeat in the order of the attachments"
+// @Param relationship formData string false "AFRelationship: Source, Data, Alternative, Supplement or Unspecified"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/attachments/add [post]
+func AddAttachments(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "attached",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	var opts struct {
+		Relationship string `json:"relationship" form:"relationship"`
+	}
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid attachment options")
+	}
+
+	if opts.Relationship != "" && !slices.Contains(afRelationships, opts.Relationship) {
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			"Invalid relationship. Use "+strings.Join(afRelationships, ", "),
+		)
+	}
+
+	uploads, uploadErr := attachmentUploads(ctx)
+	if uploadErr != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(uploadErr))
+	}
+
+	pdfCtx, readErr := readAttachmentsContext(result.InputPath, result.Password, model.ADDATTACHMENTS)
+	if readErr != nil {
+		log.Error().Err(readErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			"File is invalid, corrupted or the password is wrong.",
+		)
+	}
+
+	if err := addAttachments(pdfCtx, uploads, opts.Relationship); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			helper.TransformPDFCPUErrorToResponse(err),
+		)
+	}
+
+	if err := api.WriteContextFile(pdfCtx, result.OutputPath); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusInternalServerError,
+			helper.TransformPDFCPUErrorToResponse(err),
+		)
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// @Summary Extract embedded files
+// @Description Returns a single embedded file when names selects exactly one, otherwise a ZIP archive of the selected or all files.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce octet-stream,application/zip
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file"
+// @Param request body object false "JSON request with base64 PDF"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Param names formData string false "Comma separated attachment names, defaults to all"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 404 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/attachments/extract [post]
+func ExtractAttachments(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "attachments",
+		OutputExt:       ".zip",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	var opts struct {
+		Names string `json:"names" form:"names"`
+	}
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid extract options")
+	}
+	names := attachmentNames(opts.Names)
+
+	pdfCtx, readErr := readAttachmentsContext(result.InputPath, result.Password, model.EXTRACTATTACHMENTS)
+	if readErr != nil {
+		log.Error().Err(readErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			"File is invalid, corrupted or the password is wrong.",
+		)
+	}
+
+	attachments, extractErr := extractAttachments(pdfCtx, names)
+	if extractErr != nil {
+		log.Error().Err(extractErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusInternalServerError,
+			helper.TransformPDFCPUErrorToResponse(extractErr),
+		)
+	}
+
+	if len(attachments) == 0 {
+		return helper.SendErrorResponse(ctx, fiber.StatusNotFound, "No attachments found")
+	}
+
+	if len(names) == 1 && len(attachments) == 1 {
+		a := attachments[0]
+		if err := writeAttachment(result.OutputPath, a); err != nil {
+			log.Error().Err(err).Caller().Send()
+			return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, "Failed to write attachment")
+		}
+		return ctx.Download(result.OutputPath, filepath.Base(a.FileName))
+	}
+
+	if err := zipAttachments(result.OutputPath, attachments); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, "Failed to create ZIP archive")
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// @Summary Remove embedded files
+// @Description Removes the named file attachments, or all of them with all set.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce octet-stream
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file"
+// @Param request body object false "JSON request with base64 PDF"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Param names formData string false "Comma separated attachment names"
+// @Param all formData bool false "Remove all attachments"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 404 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/attachments/remove [post]
+func RemoveAttachments(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "detached",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	var opts struct {
+		Names string `json:"names" form:"names"`
+		All   bool   `json:"all" form:"all"`
+	}
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid remove options")
+	}
+
+	names := attachmentNames(opts.Names)
+	if len(names) == 0 && !opts.All {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Names or all is required")
+	}
+	if opts.All {
+		names = nil
+	}
+
+	pdfCtx, readErr := readAttachmentsContext(result.InputPath, result.Password, model.REMOVEATTACHMENTS)
+	if readErr != nil {
+		log.Error().Err(readErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			"File is invalid, corrupted or the password is wrong.",
+		)
+	}
+
+	removed, removeErr := removeAttachments(pdfCtx, names)
+	if removeErr != nil {
+		log.Error().Err(removeErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			helper.TransformPDFCPUErrorToResponse(removeErr),
+		)
+	}
+	if !removed {
+		return helper.SendErrorResponse(ctx, fiber.StatusNotFound, "No attachments found")
+	}
+
+	if err := api.WriteContextFile(pdfCtx, result.OutputPath); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusInternalServerError,
+			helper.TransformPDFCPUErrorToResponse(err),
+		)
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// readAttachmentsContext reads and validates inFile, decrypting it with password if needed.
+func readAttachmentsContext(inFile, password string, cmd model.CommandMode) (*model.Context, error) {
+	f, err := os.Open(inFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = cmd
+	conf.UserPW = password
+	conf.OwnerPW = password
+
+	return api.ReadValidateAndOptimize(f, conf)
+}
+
+// listAttachments returns the attachments of pdfCtx without reading their content. The
+// size is the /Size of the embedded file's parameters, only files without one are
+// decoded to measure them.
+func listAttachments(pdfCtx *model.Context) ([]attachmentInfo, error) {
+	list := []attachmentInfo{}
+	if err := pdfCtx.LocateNameTree("EmbeddedFiles", false); err != nil {
+		return nil, err
+	}
+	tree := pdfCtx.Names["EmbeddedFiles"]
+	if tree == nil {
+		return list, nil
+	}
+
+	err := tree.Process(pdfCtx.XRefTable, func(xRefTable *model.XRefTable, id string, o *pdftypes.Object) error {
+		spec, err := xRefTable.DereferenceDict(*o)
+		if err != nil || spec == nil {
+			return err
+		}
+
+		info := attachmentInfo{ID: id}
+		if info.Description, err = specString(xRefTable, spec, "Desc"); err != nil {
+			return err
+		}
+		if info.FileName, err = specString(xRefTable, spec, "UF"); err != nil {
+			return err
+		}
+		if info.FileName == "" {
+			if info.FileName, err = specString(xRefTable, spec, "F"); err != nil {
+				return err
+			}
+		}
+
+		ef, err := xRefTable.DereferenceDict(spec["EF"])
+		if err != nil || ef == nil {
+			return err
+		}
+		sd, _, err := xRefTable.DereferenceStreamDict(ef["F"])
+		if err != nil || sd == nil {
+			return err
+		}
+
+		params, err := xRefTable.DereferenceDict(sd.Dict["Params"])
+		if err != nil {
+			return err
+		}
+		if s := params.StringEntry("ModDate"); s != nil {
+			if modTime, ok := pdftypes.DateTime(*s, true); ok {
+				info.Modified = &modTime
+			}
+		}
+		switch size := params.IntEntry("Size"); {
+		case size != nil:
+			info.Size = *size
+		case sd.FilterPipeline == nil:
+			info.Size = len(sd.Raw)
+		default:
+			if err := sd.Decode(); err != nil {
+				return err
+			}
+			info.Size = len(sd.Content)
+		}
+
+		list = append(list, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// specString returns the text string key of the file specification spec, empty if it has none.
+func specString(xRefTable *model.XRefTable, spec pdftypes.Dict, key string) (string, error) {
+	if spec[key] == nil {
+		return "", nil
+	}
+	return xRefTable.DereferenceStringOrHexLiteral(spec[key], model.V10, nil)
+}
+
+// extractAttachments returns the attachments selected by names, or all of them, with their content.
+// Unlike pdfcpu it returns an empty list for documents without attachments.
+func extractAttachments(pdfCtx *model.Context, names []string) ([]model.Attachment, error) {
+	list, err := pdfCtx.ListAttachments()
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+
+	return pdfCtx.ExtractAttachments(names)
+}
+
+// attachmentNames splits a comma separated list of attachment names.
+func attachmentNames(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// attachmentUploads reads the files to embed from the "attachment" form files or the
+// attachments array of a JSON body.
+func attachmentUploads(ctx fiber.Ctx) ([]attachmentUpload, error) {
+	var uploads []attachmentUpload
+
+	if strings.HasPrefix(ctx.Get("Content-Type"), "multipart/form-data") {
+		form, err := ctx.MultipartForm()
+		if err != nil {
+			return nil, fmt.Errorf("invalid multipart form")
+		}
+
+		descriptions := form.Value["description"]
+		for i, file := range form.File["attachment"] {
+			f, err := file.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read attachment %s", file.Filename)
+			}
+			data, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read attachment %s", file.Filename)
+			}
+
+			upload := attachmentUpload{FileName: file.Filename, data: data}
+			if i < len(descriptions) {
+				upload.Description = descriptions[i]
+			}
+			uploads = append(uploads, upload)
+		}
+	} else {
+		var request struct {
+			Attachments []attachmentUpload `json:"attachments"`
+		}
+		if err := ctx.Bind().Body(&request); err != nil {
+			return nil, fmt.Errorf("invalid attachments")
+		}
+
+		for _, upload := range request.Attachments {
+			data, err := base64.StdEncoding.DecodeString(upload.Base64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 data for attachment %s", upload.FileName)
+			}
+			upload.data = data
+			uploads = append(uploads, upload)
+		}
+	}
+
+	if len(uploads) == 0 {
+		return nil, fmt.Errorf("no attachment uploaded")
+	}
+
+	for i, upload := range uploads {
+		name := filepath.Base(upload.FileName)
+		if name == "." || name == "/" || name == "" {
+			return nil, fmt.Errorf("attachment filename is required")
+		}
+		if len(upload.data) == 0 {
+			return nil, fmt.Errorf("attachment %s cannot be empty", name)
+		}
+		uploads[i].FileName = name
+	}
+
+	return uploads, nil
+}
+
+// addAttachments embeds uploads into pdfCtx. A non empty relationship also marks each file
+// as an associated file of the document: the file spec gets an AFRelationship, the embedded
+// stream its MIME subtype, and the catalog AF array a reference to the file spec.
+func addAttachments(pdfCtx *model.Context, uploads []attachmentUpload, relationship string) error {
+	xRefTable := pdfCtx.XRefTable
+	if err := xRefTable.LocateNameTree("EmbeddedFiles", true); err != nil {
+		return err
+	}
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, upload := range uploads {
+		a := model.Attachment{
+			Reader:  bytes.NewReader(upload.data),
+			ID:      upload.FileName,
+			Desc:    upload.Description,
+			ModTime: &now,
+		}
+
+		d, err := xRefTable.NewFileSpecDictForAttachment(a)
+		if err != nil {
+			return err
+		}
+
+		if relationship != "" {
+			d.InsertName("AFRelationship", relationship)
+
+			if mimeType := mime.TypeByExtension(filepath.Ext(upload.FileName)); mimeType != "" {
+				if efDict := d.DictEntry("EF"); efDict != nil {
+					if indRef := efDict.IndirectRefEntry("F"); indRef != nil {
+						sd, _, err := xRefTable.DereferenceStreamDict(*indRef)
+						if err != nil {
+							return err
+						}
+						sd.InsertName("Subtype", strings.SplitN(mimeType, ";", 2)[0])
+					}
+				}
+			}
+		}
+
+		ir, err := xRefTable.IndRefForNewObject(d)
+		if err != nil {
+			return err
+		}
+
+		m := model.NameMap{a.ID: []pdftypes.Dict{d}}
+		if err := xRefTable.Names["EmbeddedFiles"].Add(xRefTable, a.ID, *ir, m, []string{"F", "UF"}); err != nil {
+			return err
+		}
+
+		if relationship != "" {
+			af, err := xRefTable.DereferenceArray(rootDict["AF"])
+			if err != nil {
+				return err
+			}
+			rootDict["AF"] = append(af, *ir)
+		}
+	}
+
+	return nil
+}
+
+// removeAttachments removes the attachments selected by names, or all of them, and drops the
+// removed file specs from the catalog AF array. It reports whether anything was removed.
+func removeAttachments(pdfCtx *model.Context, names []string) (bool, error) {
+	list, err := pdfCtx.ListAttachments()
+	if err != nil || len(list) == 0 {
+		return false, err
+	}
+
+	removed, err := pdfCtx.RemoveAttachments(names)
+	if err != nil || !removed {
+		return removed, err
+	}
+
+	rootDict, err := pdfCtx.Catalog()
+	if err != nil {
+		return false, err
+	}
+
+	af, err := pdfCtx.DereferenceArray(rootDict["AF"])
+	if err != nil || af == nil {
+		return true, err
+	}
+
+	remaining := map[pdftypes.IndirectRef]bool{}
+	if tree := pdfCtx.Names["EmbeddedFiles"]; tree != nil {
+		collect := func(_ *model.XRefTable, _ string, o *pdftypes.Object) error {
+			if indRef, ok := (*o).(pdftypes.IndirectRef); ok {
+				remaining[indRef] = true
+			}
+			return nil
+		}
+		if err := tree.Process(pdfCtx.XRefTable, collect); err != nil {
+			return false, err
+		}
+	}
+
+	kept := pdftypes.Array{}
+	for _, o := range af {
+		if indRef, ok := o.(pdftypes.IndirectRef); ok && !remaining[indRef] {
+			continue
+		}
+		kept = append(kept, o)
+	}
+
+	if len(kept) == 0 {
+		delete(rootDict, "AF")
+	} else {
+		rootDict["AF"] = kept
+	}
+
+	return true, nil
+}
+
+// writeAttachment writes the content of a to path.
+func writeAttachment(path string, a model.Attachment) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, a); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// zipAttachments writes attachments into a ZIP archive at zipPath, one entry per file.
+// Files of the same name are numbered, e.g. "name (2).ext".
+func zipAttachments(zipPath string, attachments []model.Attachment) error {
+	out, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	defer zw.Close()
+
+	used := map[string]bool{}
+	for _, a := range attachments {
+		w, err := zw.Create(uniqueEntryName(filepath.Base(a.FileName), used))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, a); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// uniqueEntryName returns name, or the first of "name (2).ext", "name (3).ext" and so on
+// not in used, and adds it to used.
+func uniqueEntryName(name string, used map[string]bool) string {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for n := 2; used[name]; n++ {
+		name = fmt.Sprintf("%s (%d)%s", stem, n, ext)
+	}
+	used[name] = true
+	return name
+}
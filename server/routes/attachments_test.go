@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"archive/zip"
+	"fmt"
+	"path/filepath"
+	"pdftool/internal/pdftest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func TestListAttachments(t *testing.T) {
+	var b pdftest.Builder
+	// The first file is not decoded, its content is not valid Flate data
+	sized := b.Add(pdftest.Stream("/Type /EmbeddedFile /Filter /FlateDecode /Params << /Size 5 >>", "not flate"))
+	unsized := b.Add(pdftest.Stream("/Type /EmbeddedFile", "hi!"))
+	spec := "<< /Type /Filespec /F (%s) /UF (%s) /Desc (%s) /EF << /F %d 0 R >> >>"
+	root := b.Document("/MediaBox [0 0 200 200]")
+	b.Set(root, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R /Names << /EmbeddedFiles << /Names [(a.txt) %d 0 R (b.txt) %d 0 R] >> >> >>",
+		root-2, b.Add(fmt.Sprintf(spec, "a.txt", "a.txt", "sized", sized)), b.Add(fmt.Sprintf(spec, "b.txt", "b.txt", "unsized", unsized))))
+
+	pdfCtx, err := readAttachmentsContext(b.Write(t, root), "", model.LISTATTACHMENTS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := listAttachments(pdfCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []attachmentInfo{
+		{ID: "a.txt", FileName: "a.txt", Description: "sized", Size: 5},
+		{ID: "b.txt", FileName: "b.txt", Description: "unsized", Size: 3},
+	}
+	if !slices.Equal(list, want) {
+		t.Errorf("got attachments %+v, want %+v", list, want)
+	}
+}
+
+func TestZipAttachmentsNames(t *testing.T) {
+	var attachments []model.Attachment
+	for _, name := range []string{"a.txt", "dir/a.txt", "b", "a.txt", "b"} {
+		attachments = append(attachments, model.Attachment{Reader: strings.NewReader(name), FileName: name})
+	}
+
+	zipPath := filepath.Join(t.TempDir(), "attachments.zip")
+	if err := zipAttachments(zipPath, attachments); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if want := []string{"a.txt", "a (2).txt", "b", "a (3).txt", "b (2)"}; !slices.Equal(names, want) {
+		t.Errorf("got entries %q, want %q", names, want)
+	}
+}
@@ -0,0 +1,441 @@
+package routes
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// contentOp is one operator of a content stream with its operands. String operands
+// hold the decoded bytes, literal and hex strings alike. An inline image is a single
+// BI operator whose operands are the image dict and the image data.
+type contentOp struct {
+	Operator string
+	Operands []types.Object
+}
+
+// matrix is a PDF transformation matrix [a b c d e f].
+type matrix [6]float64
+
+var identityMatrix = matrix{1, 0, 0, 1, 0, 0}
+
+// multiply returns m × n, that is m applied first and n second.
+func (m matrix) multiply(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// apply transforms the point (x, y).
+func (m matrix) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// scale returns the length the unit vectors along x and y have after the transformation.
+func (m matrix) scale() (float64, float64) {
+	return math.Hypot(m[0], m[1]), math.Hypot(m[2], m[3])
+}
+
+// inverse returns the matrix undoing m, or the identity if m can not be undone.
+func (m matrix) inverse() matrix {
+	det := m[0]*m[3] - m[1]*m[2]
+	if det == 0 {
+		return identityMatrix
+	}
+	return matrix{
+		m[3] / det,
+		-m[1] / det,
+		-m[2] / det,
+		m[0] / det,
+		(m[2]*m[5] - m[3]*m[4]) / det,
+		(m[1]*m[4] - m[0]*m[5]) / det,
+	}
+}
+
+// operandMatrix reads a matrix from six number operands, as used by cm, Tm and the Matrix entries.
+func operandMatrix(operands []types.Object) (matrix, bool) {
+	if len(operands) != 6 {
+		return identityMatrix, false
+	}
+	var m matrix
+	for i, o := range operands {
+		v, ok := operandNumber(o)
+		if !ok {
+			return identityMatrix, false
+		}
+		m[i] = v
+	}
+	return m, true
+}
+
+// operandNumber returns the value of an integer or real operand.
+func operandNumber(o types.Object) (float64, bool) {
+	switch o := o.(type) {
+	case types.Integer:
+		return float64(o), true
+	case types.Float:
+		return float64(o), true
+	}
+	return 0, false
+}
+
+// parseContent splits a content stream into its operators.
+func parseContent(b []byte) ([]contentOp, error) {
+	l := &contentLexer{b: b}
+
+	var (
+		ops      []contentOp
+		operands []types.Object
+	)
+	for {
+		o, keyword, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		if o == nil && keyword == "" {
+			break
+		}
+		switch keyword {
+		case "":
+			operands = append(operands, o)
+		case "null":
+			operands = append(operands, nil)
+		case "BI":
+			d, data, err := l.inlineImage()
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, contentOp{Operator: "BI", Operands: []types.Object{d, types.StringLiteral(data)}})
+			operands = nil
+		default:
+			ops = append(ops, contentOp{Operator: keyword, Operands: operands})
+			operands = nil
+		}
+	}
+
+	return ops, nil
+}
+
+// contentLexer reads the tokens of a content stream.
+type contentLexer struct {
+	b   []byte
+	pos int
+}
+
+func isContentSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isContentDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+// skipSpace skips whitespace and comments.
+func (l *contentLexer) skipSpace() {
+	for l.pos < len(l.b) {
+		c := l.b[l.pos]
+		switch {
+		case isContentSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.b) && l.b[l.pos] != '\n' && l.b[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// next returns the next operand or, for anything else, the keyword. Both are empty at the end.
+func (l *contentLexer) next() (types.Object, string, error) {
+	l.skipSpace()
+	if l.pos >= len(l.b) {
+		return nil, "", nil
+	}
+
+	switch c := l.b[l.pos]; c {
+	case '/':
+		l.pos++
+		return types.Name(l.name()), "", nil
+	case '(':
+		l.pos++
+		s, err := l.literalString()
+		return types.StringLiteral(s), "", err
+	case '<':
+		if l.pos+1 < len(l.b) && l.b[l.pos+1] == '<' {
+			l.pos += 2
+			d, err := l.dict(">>")
+			return d, "", err
+		}
+		l.pos++
+		s, err := l.hexString()
+		return types.StringLiteral(s), "", err
+	case '[':
+		l.pos++
+		a, err := l.array()
+		return a, "", err
+	case ']', '>', ')', '{', '}':
+		// Stray delimiters carry no meaning in a content stream
+		l.pos++
+		return l.next()
+	}
+
+	start := l.pos
+	for l.pos < len(l.b) && !isContentSpace(l.b[l.pos]) && !isContentDelimiter(l.b[l.pos]) {
+		l.pos++
+	}
+	token := string(l.b[start:l.pos])
+
+	switch token {
+	case "true":
+		return types.Boolean(true), "", nil
+	case "false":
+		return types.Boolean(false), "", nil
+	}
+
+	if c := token[0]; c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9') {
+		return contentNumber(token), "", nil
+	}
+
+	return nil, token, nil
+}
+
+// contentNumber parses a number token, leniently as readers do: "--5" or "1.2.3" are not fatal.
+func contentNumber(token string) types.Object {
+	if i, err := strconv.Atoi(token); err == nil {
+		return types.Integer(i)
+	}
+	if f, err := strconv.ParseFloat(token, 64); err == nil {
+		return types.Float(f)
+	}
+
+	sign := 1.0
+	for len(token) > 0 && (token[0] == '+' || token[0] == '-') {
+		if token[0] == '-' {
+			sign = -1
+		}
+		token = token[1:]
+	}
+	for end := len(token); end > 0; end-- {
+		if f, err := strconv.ParseFloat(token[:end], 64); err == nil {
+			return types.Float(sign * f)
+		}
+	}
+	return types.Float(0)
+}
+
+// name reads a name after its slash, decoding #xx escapes.
+func (l *contentLexer) name() string {
+	var name []byte
+	for l.pos < len(l.b) && !isContentSpace(l.b[l.pos]) && !isContentDelimiter(l.b[l.pos]) {
+		c := l.b[l.pos]
+		if c == '#' && l.pos+2 < len(l.b) {
+			if v, err := strconv.ParseUint(string(l.b[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				name = append(name, byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		name = append(name, c)
+		l.pos++
+	}
+	return string(name)
+}
+
+// literalString reads a string after its opening parenthesis, resolving escapes.
+func (l *contentLexer) literalString() ([]byte, error) {
+	var s []byte
+	depth := 1
+	for l.pos < len(l.b) {
+		c := l.b[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return s, nil
+			}
+		case '\r':
+			// An end of line in a string is a single newline, whatever its form
+			if l.pos < len(l.b) && l.b[l.pos] == '\n' {
+				l.pos++
+			}
+			c = '\n'
+		case '\\':
+			if l.pos >= len(l.b) {
+				continue
+			}
+			c = l.b[l.pos]
+			l.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.b) && l.b[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for i := 0; i < 2 && l.pos < len(l.b) && l.b[l.pos] >= '0' && l.b[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.b[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	return nil, fmt.Errorf("unterminated string in content stream")
+}
+
+// hexString reads a hex string after its opening angle bracket.
+func (l *contentLexer) hexString() ([]byte, error) {
+	var (
+		s    []byte
+		v    byte
+		half bool
+	)
+	for l.pos < len(l.b) {
+		c := l.b[l.pos]
+		l.pos++
+
+		var d byte
+		switch {
+		case c == '>':
+			if half {
+				s = append(s, v<<4)
+			}
+			return s, nil
+		case c >= '0' && c <= '9':
+			d = c - '0'
+		case c >= 'a' && c <= 'f':
+			d = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			d = c - 'A' + 10
+		default:
+			continue
+		}
+
+		if half {
+			s = append(s, v<<4|d)
+		} else {
+			v = d
+		}
+		half = !half
+	}
+	return nil, fmt.Errorf("unterminated hex string in content stream")
+}
+
+// array reads array elements after the opening bracket.
+func (l *contentLexer) array() (types.Array, error) {
+	a := types.Array{}
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.b) {
+			return nil, fmt.Errorf("unterminated array in content stream")
+		}
+		if l.b[l.pos] == ']' {
+			l.pos++
+			return a, nil
+		}
+
+		o, keyword, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		if keyword != "" && keyword != "null" {
+			return nil, fmt.Errorf("unexpected %q in content stream array", keyword)
+		}
+		a = append(a, o)
+	}
+}
+
+// dict reads key value pairs until end, ">>" for a dict and "ID" for inline image parameters.
+func (l *contentLexer) dict(end string) (types.Dict, error) {
+	d := types.NewDict()
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.b) {
+			return nil, fmt.Errorf("unterminated dict in content stream")
+		}
+		if bytes.HasPrefix(l.b[l.pos:], []byte(end)) {
+			l.pos += len(end)
+			return d, nil
+		}
+
+		key, keyword, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(types.Name)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %q in content stream dict", keyword)
+		}
+
+		value, keyword, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		if keyword != "" && keyword != "null" {
+			return nil, fmt.Errorf("unexpected %q in content stream dict", keyword)
+		}
+		d[string(name)] = value
+	}
+}
+
+// inlineImage reads the parameters and data of an inline image after its BI operator.
+func (l *contentLexer) inlineImage() (types.Dict, []byte, error) {
+	d, err := l.dict("ID")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A single white space separates ID from the data
+	if l.pos < len(l.b) && isContentSpace(l.b[l.pos]) {
+		l.pos++
+	}
+
+	start := l.pos
+	for i := start; i+1 < len(l.b); i++ {
+		if l.b[i] != 'E' || l.b[i+1] != 'I' {
+			continue
+		}
+		if i > start && !isContentSpace(l.b[i-1]) {
+			continue
+		}
+		if i+2 < len(l.b) && !isContentSpace(l.b[i+2]) && !isContentDelimiter(l.b[i+2]) {
+			continue
+		}
+		end := i
+		if end > start && isContentSpace(l.b[end-1]) {
+			end--
+		}
+		l.pos = i + 2
+		return d, l.b[start:end], nil
+	}
+
+	return nil, nil, fmt.Errorf("unterminated inline image in content stream")
+}
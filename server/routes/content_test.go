@@ -0,0 +1,40 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func TestParseContent(t *testing.T) {
+	ops, err := parseContent([]byte("q 1 0 0 1 10 -5.5 cm /Im1 Do Q BT [(a) -20 <6162>] TJ ET BI /W 2 /H 1 ID \x00\xff EI /P << /MCID 0 >> BDC EMC"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var operators []string
+	for _, op := range ops {
+		operators = append(operators, op.Operator)
+	}
+	if got, want := len(operators), 10; got != want {
+		t.Fatalf("got operators %v, want %d", operators, want)
+	}
+	if m, ok := operandMatrix(ops[1].Operands); !ok || m != (matrix{1, 0, 0, 1, 10, -5.5}) {
+		t.Errorf("got cm matrix %v", m)
+	}
+	if name, ok := ops[2].Operands[0].(types.Name); !ok || name != "Im1" {
+		t.Errorf("got Do operand %v", ops[2].Operands[0])
+	}
+	if data, ok := ops[7].Operands[1].(types.StringLiteral); ops[7].Operator != "BI" || !ok || data != "\x00\xff" {
+		t.Errorf("got inline image %v", ops[7])
+	}
+}
+
+// FuzzParseContent runs its seeds with go test, go test -fuzz FuzzParseContent explores further.
+func FuzzParseContent(f *testing.F) {
+	f.Add([]byte("BT /F1 12 Tf 20 100 Td (Text) Tj ET"))
+	f.Add([]byte("q 40 0 0 40 55 5 cm BI /W 2 /H 2 /CS /G /BPC 8 ID \x00\x80\xc0\xff EI Q"))
+	f.Add([]byte("[(a) -20 <6162>] TJ /P << /MCID 0 /A [1 2.5 -.5] >> BDC (\\(\\053\\n) Tj EMC"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, _ = parseContent(b)
+	})
+}
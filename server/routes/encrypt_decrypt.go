@@ -1,7 +1,11 @@
 package routes
 
 import (
+	"bytes"
+	"fmt"
+	"os"
 	"pdftool/server/helper"
+	"strings"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/pdfcpu/pdfcpu/pkg/api"
@@ -9,6 +13,12 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+type encryptOptions struct {
+	OwnerPassword string `json:"owner_password" form:"owner_password"`
+	Permissions   string `json:"permissions" form:"permissions"`
+	Algorithm     string `json:"algorithm" form:"algorithm"`
+}
+
 // @Summary Encrypt a PDF file
 // @Description Encrypts a PDF file with password protection
 // @Tags PDF Operations
@@ -18,6 +28,9 @@ import (
 // @Param file formData file false "PDF file to encrypt"
 // @Param request body object false "JSON request with base64 PDF"
 // @Param pdf_password formData string true "Password to encrypt the PDF"
+// @Param owner_password formData string false "Owner password, defaults to pdf_password"
+// @Param permissions formData string false "Comma separated: print, print_high_quality, copy, modify, annotate, fill_forms, assemble, all or none (default)"
+// @Param algorithm formData string false "aes-128 or aes-256 (default)"
 // @Success 200 {file} binary
 // @Failure 400 {object} types.Response
 // @Failure 401 {object} types.Response
@@ -33,24 +46,13 @@ func Encrypt(ctx fiber.Ctx) error {
 	}
 	defer result.Cleanup()
 
-	if err := api.ValidateFile(result.InputPath, nil); err != nil {
-		return helper.SendErrorResponse(
-			ctx,
-			fiber.StatusBadRequest,
-			"File is invalid or corrupted. Please upload a valid PDF.",
-		)
+	var opts encryptOptions
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid encrypt options")
 	}
 
-	conf := model.NewAESConfiguration(result.Password, result.Password, 256)
-	conf.Permissions = model.PermissionsNone
-
-	if err := api.EncryptFile(result.InputPath, result.OutputPath, conf); err != nil {
-		log.Error().Err(err).Caller().Send()
-		return helper.SendErrorResponse(
-			ctx,
-			fiber.StatusInternalServerError,
-			helper.TransformPDFCPUErrorToResponse(err),
-		)
+	if stepErr := encryptPDF(result.InputPath, result.OutputPath, "", result.Password, opts); stepErr != nil {
+		return helper.SendErrorResponse(ctx, stepErr.code, stepErr.message)
 	}
 
 	return ctx.Download(result.OutputPath, result.OutputName)
@@ -80,26 +82,306 @@ func Decrypt(ctx fiber.Ctx) error {
 	}
 	defer result.Cleanup()
 
-	if err := api.ValidateFile(result.InputPath, nil); err != nil {
-		return helper.SendErrorResponse(
-			ctx,
-			fiber.StatusBadRequest,
-			"File is invalid or corrupted. Please upload a valid PDF.",
-		)
+	if stepErr := decryptPDF(result.InputPath, result.OutputPath, result.Password); stepErr != nil {
+		return helper.SendErrorResponse(ctx, stepErr.code, stepErr.message)
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+type reencryptOptions struct {
+	OwnerPassword    string  `json:"owner_password" form:"owner_password"`
+	NewUserPassword  *string `json:"new_user_password" form:"new_user_password"`
+	NewOwnerPassword *string `json:"new_owner_password" form:"new_owner_password"`
+	Permissions      *string `json:"permissions" form:"permissions"`
+}
+
+// @Summary Change passwords or permissions of an encrypted PDF
+// @Description Re-encrypts an encrypted PDF with a new user and/or owner password or permission set in one call,
+// @Description without the decrypted document leaving the server.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce octet-stream
+// @Security ApiKeyAuth
+// @Param file formData file false "Encrypted PDF file"
+// @Param request body object false "JSON request with base64 PDF"
+// @Param pdf_password formData string true "Current user password"
+// @Param owner_password formData string false "Current owner password, defaults to pdf_password"
+// @Param new_user_password formData string false "New user password"
+// @Param new_owner_password formData string false "New owner password"
+// @Param permissions formData string false "New permissions, comma separated: print, print_high_quality, copy, modify, annotate, fill_forms, assemble, all or none"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/reencrypt [post]
+func Reencrypt(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: true,
+		OutputPrefix:    "encrypted",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	var opts reencryptOptions
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid re-encrypt options")
+	}
+
+	if stepErr := reencryptPDF(result.InputPath, result.OutputPath, result.Password, opts); stepErr != nil {
+		return helper.SendErrorResponse(ctx, stepErr.code, stepErr.message)
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// encryptPDF encrypts inFile with the user password userPW as opts tell and writes outFile.
+// If inFile is encrypted already, password opens it and its encryption is replaced.
+func encryptPDF(inFile, outFile, password, userPW string, opts encryptOptions) *stepError {
+	if err := api.ValidateFile(inFile, passwordConfiguration(password)); err != nil {
+		return newStepError(fiber.StatusBadRequest, "File is invalid, corrupted or the password is wrong.")
+	}
+
+	keyLength, keyErr := encryptKeyLength(opts.Algorithm)
+	if keyErr != nil {
+		return newStepError(fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(keyErr))
+	}
+
+	permissions, permErr := parsePermissions(opts.Permissions)
+	if permErr != nil {
+		return newStepError(fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(permErr))
+	}
+
+	ownerPassword := opts.OwnerPassword
+	if ownerPassword == "" {
+		ownerPassword = userPW
+	}
+
+	conf := model.NewAESConfiguration(userPW, ownerPassword, keyLength)
+	conf.Permissions = permissions
+
+	if err := encryptFile(inFile, outFile, password, conf); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return newStepError(fiber.StatusInternalServerError, helper.TransformPDFCPUErrorToResponse(err))
+	}
+
+	return nil
+}
+
+// encryptFile encrypts inFile as conf tells. An encrypted inFile is opened with password
+// and decrypted in memory first, so the plain content is never written out.
+func encryptFile(inFile, outFile, password string, conf *model.Configuration) error {
+	data, err := os.ReadFile(inFile)
+	if err != nil {
+		return err
+	}
+
+	if password != "" {
+		pdfCtx, err := api.ReadContext(bytes.NewReader(data), passwordConfiguration(password))
+		if err != nil {
+			return err
+		}
+		if pdfCtx.Encrypt != nil {
+			var buf bytes.Buffer
+			if err := api.Decrypt(bytes.NewReader(data), &buf, passwordConfiguration(password)); err != nil {
+				return err
+			}
+			data = buf.Bytes()
+		}
+	}
+
+	out, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+	if err := api.Encrypt(bytes.NewReader(data), out, conf); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// decryptPDF decrypts inFile with password and writes outFile.
+func decryptPDF(inFile, outFile, password string) *stepError {
+	conf := passwordConfiguration(password)
+
+	if err := api.ValidateFile(inFile, conf); err != nil {
+		return newStepError(fiber.StatusBadRequest, "File is invalid, corrupted or the password is wrong.")
+	}
+
+	if err := api.DecryptFile(inFile, outFile, conf); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return newStepError(fiber.StatusInternalServerError, helper.TransformPDFCPUErrorToResponse(err))
+	}
+
+	return nil
+}
+
+// reencryptPDF changes the passwords and permissions of inFile, opened with the user
+// password userPW, as opts tell and writes outFile.
+func reencryptPDF(inFile, outFile, userPW string, opts reencryptOptions) *stepError {
+	if opts.NewUserPassword == nil && opts.NewOwnerPassword == nil && opts.Permissions == nil {
+		return newStepError(fiber.StatusBadRequest, "New user password, new owner password or permissions is required")
+	}
+
+	var permissions *model.PermissionFlags
+	if opts.Permissions != nil {
+		p, permErr := parsePermissions(*opts.Permissions)
+		if permErr != nil {
+			return newStepError(fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(permErr))
+		}
+		permissions = &p
+	}
+
+	ownerPassword := opts.OwnerPassword
+	if ownerPassword == "" {
+		ownerPassword = userPW
 	}
 
 	conf := model.NewDefaultConfiguration()
-	conf.UserPW = result.Password
-	conf.OwnerPW = result.Password
+	conf.UserPW = userPW
+	conf.OwnerPW = ownerPassword
 
-	if err := api.DecryptFile(result.InputPath, result.OutputPath, conf); err != nil {
+	if err := api.ValidateFile(inFile, conf); err != nil {
+		return newStepError(fiber.StatusBadRequest, "File is invalid, corrupted or the password is wrong.")
+	}
+
+	if err := reencryptFile(inFile, outFile, userPW, ownerPassword, opts.NewUserPassword, opts.NewOwnerPassword, permissions); err != nil {
 		log.Error().Err(err).Caller().Send()
-		return helper.SendErrorResponse(
-			ctx,
-			fiber.StatusInternalServerError,
-			helper.TransformPDFCPUErrorToResponse(err),
-		)
+		return newStepError(fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(err))
 	}
 
-	return ctx.Download(result.OutputPath, result.OutputName)
+	return nil
+}
+
+// reencryptFile applies the new permissions, user password and owner password to the
+// encrypted inFile. Every step works on the encrypted document in memory, so the
+// plain content is never written out.
+func reencryptFile(inFile, outFile, userPW, ownerPW string, newUserPW, newOwnerPW *string, permissions *model.PermissionFlags) error {
+	data, err := os.ReadFile(inFile)
+	if err != nil {
+		return err
+	}
+
+	conf := func() *model.Configuration {
+		conf := model.NewDefaultConfiguration()
+		conf.UserPW = userPW
+		conf.OwnerPW = ownerPW
+		return conf
+	}
+
+	var buf bytes.Buffer
+	if permissions != nil {
+		c := conf()
+		c.Permissions = *permissions
+		if err := api.SetPermissions(bytes.NewReader(data), &buf, c); err != nil {
+			return err
+		}
+		data = bytes.Clone(buf.Bytes())
+		buf.Reset()
+	}
+
+	if newUserPW != nil {
+		if err := api.ChangeUserPassword(bytes.NewReader(data), &buf, userPW, *newUserPW, conf()); err != nil {
+			return err
+		}
+		userPW = *newUserPW
+		data = bytes.Clone(buf.Bytes())
+		buf.Reset()
+	}
+
+	if newOwnerPW != nil {
+		if err := api.ChangeOwnerPassword(bytes.NewReader(data), &buf, ownerPW, *newOwnerPW, conf()); err != nil {
+			return err
+		}
+		data = bytes.Clone(buf.Bytes())
+	}
+
+	return os.WriteFile(outFile, data, 0o600)
+}
+
+// passwordConfiguration returns a configuration opening files encrypted with password,
+// as user or owner password.
+func passwordConfiguration(password string) *model.Configuration {
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW = password
+	conf.OwnerPW = password
+	return conf
+}
+
+// encryptKeyLength maps the algorithm option to an AES key length.
+func encryptKeyLength(algorithm string) (int, error) {
+	switch strings.ToLower(algorithm) {
+	case "", "aes-256", "aes256":
+		return 256, nil
+	case "aes-128", "aes128":
+		return 128, nil
+	default:
+		return 0, fmt.Errorf("invalid algorithm %q, use aes-128 or aes-256", algorithm)
+	}
+}
+
+// parsePermissions turns a comma separated permission list into pdfcpu permission flags.
+// An empty list grants nothing, like PermissionsNone.
+func parsePermissions(s string) (model.PermissionFlags, error) {
+	permissions := model.PermissionsNone
+	for _, p := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(p)) {
+		case "", "none":
+		case "all":
+			permissions = model.PermissionsAll
+		case "print":
+			permissions |= model.PermissionPrintRev2
+		case "print_high_quality":
+			permissions |= model.PermissionPrintRev2 | model.PermissionPrintRev3
+		case "copy":
+			permissions |= model.PermissionExtract | model.PermissionExtractRev3
+		case "modify":
+			permissions |= model.PermissionModify
+		case "annotate":
+			permissions |= model.PermissionModAnnFillForm
+		case "fill_forms":
+			permissions |= model.PermissionFillRev3
+		case "assemble":
+			permissions |= model.PermissionAssembleRev3
+		default:
+			return 0, fmt.Errorf("invalid permission %q", strings.TrimSpace(p))
+		}
+	}
+
+	return permissions, nil
+}
+
+// permissionNames is the inverse of parsePermissions, listing the permissions granted by
+// the P entry of an encryption dict in the same vocabulary.
+func permissionNames(p int) []string {
+	flags := model.PermissionFlags(p)
+	has := func(f model.PermissionFlags) bool { return flags&f == f }
+
+	names := []string{}
+	if has(model.PermissionPrintRev2) {
+		names = append(names, "print")
+	}
+	if has(model.PermissionPrintRev2 | model.PermissionPrintRev3) {
+		names = append(names, "print_high_quality")
+	}
+	if has(model.PermissionExtract) || has(model.PermissionExtractRev3) {
+		names = append(names, "copy")
+	}
+	if has(model.PermissionModify) {
+		names = append(names, "modify")
+	}
+	if has(model.PermissionModAnnFillForm) {
+		names = append(names, "annotate")
+	}
+	if has(model.PermissionFillRev3) {
+		names = append(names, "fill_forms")
+	}
+	if has(model.PermissionAssembleRev3) {
+		names = append(names, "assemble")
+	}
+
+	return names
 }
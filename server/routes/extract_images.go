@@ -0,0 +1,229 @@
+package routes
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"pdftool/server/helper"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v3"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/rs/zerolog/log"
+)
+
+// extractedImage is one entry of the manifest.json shipped with the extracted images.
+type extractedImage struct {
+	File       string `json:"file"`
+	Page       int    `json:"page"`
+	ObjectID   int    `json:"object_id"`
+	Name       string `json:"name"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Filter     string `json:"filter"`
+	ColorSpace string `json:"color_space"`
+	BPC        int    `json:"bits_per_component"`
+	Size       int64  `json:"size"` // bytes of the file in the archive
+}
+
+// @Summary Extract embedded images
+// @Description Extracts every embedded image, or the images on the selected pages, in its native format.
+// @Description Returns a ZIP archive with the images and a manifest.json listing page, object id, dimensions, filter
+// @Description and the size of each image file.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce application/zip
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file to extract images from"
+// @Param request body object false "JSON request with base64 PDF"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Param pages formData string false "Page selection, defaults to all pages"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/extract-images [post]
+func ExtractImages(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "images",
+		OutputExt:       ".zip",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	var opts struct {
+		Pages string `json:"pages" form:"pages"`
+	}
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid extract options")
+	}
+
+	selectedPages, selErr := api.ParsePageSelection(opts.Pages)
+	if selErr != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid page selection")
+	}
+
+	if err := api.ValidateFile(result.InputPath, passwordConfiguration(result.Password)); err != nil {
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			"File is invalid, corrupted or the password is wrong.",
+		)
+	}
+
+	if err := extractImagesToZip(result.InputPath, result.OutputPath, result.Password, selectedPages); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusInternalServerError,
+			helper.TransformPDFCPUErrorToResponse(err),
+		)
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// extractImagesToZip writes the images of the selected pages of inFile, opened with
+// password, ordered by page and object number, plus a manifest.json into a ZIP archive
+// at zipPath.
+func extractImagesToZip(inFile, zipPath, password string, selectedPages []string) error {
+	f, err := os.Open(inFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	conf := passwordConfiguration(password)
+	conf.Cmd = model.EXTRACTIMAGES
+
+	pdfCtx, err := api.ReadValidateAndOptimize(f, conf)
+	if err != nil {
+		return err
+	}
+
+	pages, err := api.PagesForPageSelection(pdfCtx.PageCount, selectedPages, true, true)
+	if err != nil {
+		return err
+	}
+
+	pageNrs := make([]int, 0, len(pages))
+	for pageNr, selected := range pages {
+		if selected {
+			pageNrs = append(pageNrs, pageNr)
+		}
+	}
+	slices.Sort(pageNrs)
+
+	out, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	defer zw.Close()
+
+	manifest := []extractedImage{}
+	for _, pageNr := range pageNrs {
+		images, err := pdfcpu.ExtractPageImages(pdfCtx, pageNr, false)
+		if err != nil {
+			return err
+		}
+
+		objNrs := make([]int, 0, len(images))
+		for objNr := range images {
+			objNrs = append(objNrs, objNr)
+		}
+		slices.Sort(objNrs)
+
+		for _, objNr := range objNrs {
+			img := images[objNr]
+			name := fmt.Sprintf("page_%d_obj_%d.%s", pageNr, objNr, img.FileType)
+
+			w, err := zw.Create(name)
+			if err != nil {
+				return err
+			}
+			size, err := io.Copy(w, img)
+			if err != nil {
+				return err
+			}
+
+			entry := extractedImage{
+				File:     name,
+				Page:     pageNr,
+				ObjectID: objNr,
+				Name:     img.Name,
+				Size:     size,
+			}
+			if err := imageStreamInfo(pdfCtx, objNr, &entry); err != nil {
+				return err
+			}
+			manifest = append(manifest, entry)
+		}
+	}
+
+	w, err := zw.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(manifest); err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// imageStreamInfo fills the dimensions, filter and color space of entry from the image stream dict.
+func imageStreamInfo(pdfCtx *model.Context, objNr int, entry *extractedImage) error {
+	sd, _, err := pdfCtx.DereferenceStreamDict(*types.NewIndirectRef(objNr, 0))
+	if err != nil || sd == nil {
+		return err
+	}
+
+	if w := sd.IntEntry("Width"); w != nil {
+		entry.Width = *w
+	}
+	if h := sd.IntEntry("Height"); h != nil {
+		entry.Height = *h
+	}
+	if bpc := sd.IntEntry("BitsPerComponent"); bpc != nil {
+		entry.BPC = *bpc
+	}
+
+	filters := make([]string, 0, len(sd.FilterPipeline))
+	for _, filter := range sd.FilterPipeline {
+		filters = append(filters, filter.Name)
+	}
+	entry.Filter = strings.Join(filters, ",")
+
+	cs, err := pdfCtx.Dereference(sd.Dict["ColorSpace"])
+	if err != nil {
+		return err
+	}
+	switch cs := cs.(type) {
+	case types.Name:
+		entry.ColorSpace = cs.Value()
+	case types.Array:
+		if len(cs) > 0 {
+			if name, ok := cs[0].(types.Name); ok {
+				entry.ColorSpace = name.Value()
+			}
+		}
+	}
+
+	return nil
+}
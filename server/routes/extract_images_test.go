@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"archive/zip"
+	"fmt"
+	"path/filepath"
+	"pdftool/internal/pdftest"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+func TestExtractImagesToZipWithPassword(t *testing.T) {
+	var b pdftest.Builder
+	img := b.Add(pdftest.Stream("/Type /XObject /Subtype /Image /Width 2 /Height 2 /ColorSpace /DeviceGray /BitsPerComponent 8",
+		"\x00\xff\xff\x00"))
+	content := b.Add(pdftest.Stream("", "100 0 0 100 50 50 cm /Im1 Do"))
+	file := b.Write(t, b.Document(fmt.Sprintf("/MediaBox [0 0 200 200] /Resources << /XObject << /Im1 %d 0 R >> >> /Contents %d 0 R", img, content)))
+
+	conf := passwordConfiguration("secret")
+	if err := api.EncryptFile(file, "", conf); err != nil {
+		t.Fatal(err)
+	}
+
+	zipPath := filepath.Join(t.TempDir(), "images.zip")
+	if err := extractImagesToZip(file, zipPath, "wrong", nil); err == nil {
+		t.Error("extraction with the wrong password succeeded")
+	}
+	if err := extractImagesToZip(file, zipPath, "secret", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	if len(zr.File) != 2 {
+		t.Errorf("got %d files in the archive, want the image and the manifest", len(zr.File))
+	}
+}
@@ -0,0 +1,269 @@
+package routes
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Annotation flags (PDF 32000-1:2008, table 165) of annotations that are never displayed.
+const (
+	annotFlagHidden = 1 << 1
+	annotFlagNoView = 1 << 5
+)
+
+// flattenAnnotations draws the normal appearance of every annotation whose subtype matches
+// into the page content and removes the annotation. Hidden annotations and annotations
+// without an appearance stream are removed without being drawn.
+func flattenAnnotations(pdfCtx *model.Context, match func(subtype string) bool) error {
+	xObjectNr := 0
+
+	for pageNr := 1; pageNr <= pdfCtx.PageCount; pageNr++ {
+		pageDict, _, inhAttrs, err := pdfCtx.PageDict(pageNr, false)
+		if err != nil {
+			return err
+		}
+
+		annots, err := pdfCtx.DereferenceArray(pageDict["Annots"])
+		if err != nil || len(annots) == 0 {
+			continue
+		}
+
+		var (
+			kept    types.Array
+			content strings.Builder
+			xObject types.Dict
+		)
+		for _, o := range annots {
+			d, err := pdfCtx.DereferenceDict(o)
+			if err != nil || d == nil {
+				kept = append(kept, o)
+				continue
+			}
+
+			subtype := d.NameEntry("Subtype")
+			if subtype == nil || !match(*subtype) {
+				kept = append(kept, o)
+				continue
+			}
+
+			if flags := d.IntEntry("F"); flags != nil && *flags&(annotFlagHidden|annotFlagNoView) != 0 {
+				continue
+			}
+
+			apRef, cm, err := annotationAppearance(pdfCtx, d)
+			if err != nil {
+				return err
+			}
+			if apRef == nil {
+				continue
+			}
+
+			if xObject == nil {
+				if xObject, err = pageResources(pdfCtx, pageDict, inhAttrs, "XObject"); err != nil {
+					return err
+				}
+			}
+
+			name := fmt.Sprintf("FlatAnnot%d", xObjectNr)
+			for _, taken := xObject[name]; taken; _, taken = xObject[name] {
+				xObjectNr++
+				name = fmt.Sprintf("FlatAnnot%d", xObjectNr)
+			}
+			xObjectNr++
+
+			xObject[name] = *apRef
+			fmt.Fprintf(&content, "q %.4f %.4f %.4f %.4f %.4f %.4f cm /%s Do Q\n", cm[0], cm[1], cm[2], cm[3], cm[4], cm[5], name)
+		}
+
+		if len(kept) == len(annots) {
+			continue
+		}
+
+		if len(kept) == 0 {
+			delete(pageDict, "Annots")
+		} else {
+			pageDict["Annots"] = kept
+		}
+
+		if content.Len() > 0 {
+			if err := wrapPageContent(pdfCtx, pageDict, []byte(content.String())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// annotationAppearance returns the normal appearance stream of annotation d, as a form
+// XObject, and the matrix that maps its transformed bounding box onto the annotation rect.
+func annotationAppearance(pdfCtx *model.Context, d types.Dict) (*types.IndirectRef, [6]float64, error) {
+	var cm [6]float64
+
+	ap, err := pdfCtx.DereferenceDict(d["AP"])
+	if err != nil || ap == nil {
+		return nil, cm, err
+	}
+
+	n, err := pdfCtx.Dereference(ap["N"])
+	if err != nil || n == nil {
+		return nil, cm, err
+	}
+
+	apObj := ap["N"]
+	if states, ok := n.(types.Dict); ok {
+		// Checkboxes and radio buttons have one appearance per state
+		as := d.NameEntry("AS")
+		if as == nil {
+			return nil, cm, nil
+		}
+		apObj = states[*as]
+	}
+
+	apRef, ok := apObj.(types.IndirectRef)
+	if !ok {
+		sd, ok := apObj.(types.StreamDict)
+		if !ok {
+			return nil, cm, nil
+		}
+		indRef, err := pdfCtx.IndRefForNewObject(sd)
+		if err != nil {
+			return nil, cm, err
+		}
+		apRef = *indRef
+	}
+
+	sd, _, err := pdfCtx.DereferenceStreamDict(apRef)
+	if err != nil || sd == nil {
+		return nil, cm, err
+	}
+	sd.Dict["Type"] = types.Name("XObject")
+	sd.Dict["Subtype"] = types.Name("Form")
+
+	rect, err := pdfCtx.RectForArray(d.ArrayEntry("Rect"))
+	if err != nil || rect == nil {
+		return nil, cm, err
+	}
+
+	bbox, err := pdfCtx.RectForArray(sd.Dict.ArrayEntry("BBox"))
+	if err != nil || bbox == nil {
+		return nil, cm, err
+	}
+
+	m := [6]float64{1, 0, 0, 1, 0, 0}
+	if a := sd.Dict.ArrayEntry("Matrix"); len(a) == 6 {
+		for i, o := range a {
+			v, err := pdfCtx.DereferenceNumber(o)
+			if err != nil {
+				return nil, cm, err
+			}
+			m[i] = v
+		}
+	}
+
+	// Bounding box of the appearance after its own matrix
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range [][2]float64{
+		{bbox.LL.X, bbox.LL.Y}, {bbox.UR.X, bbox.LL.Y},
+		{bbox.LL.X, bbox.UR.Y}, {bbox.UR.X, bbox.UR.Y},
+	} {
+		x := m[0]*p[0] + m[2]*p[1] + m[4]
+		y := m[1]*p[0] + m[3]*p[1] + m[5]
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+
+	if maxX-minX == 0 || maxY-minY == 0 {
+		return nil, cm, nil
+	}
+
+	sx := rect.Width() / (maxX - minX)
+	sy := rect.Height() / (maxY - minY)
+	cm = [6]float64{sx, 0, 0, sy, rect.LL.X - minX*sx, rect.LL.Y - minY*sy}
+
+	return &apRef, cm, nil
+}
+
+// pageResources returns the resources of pageDict in category, such as XObject or Font,
+// giving the page its own resources dict first if it only inherits one.
+func pageResources(pdfCtx *model.Context, pageDict types.Dict, inhAttrs *model.InheritedPageAttrs, category string) (types.Dict, error) {
+	res, err := pdfCtx.DereferenceDict(pageDict["Resources"])
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res = types.NewDict()
+		if inhAttrs != nil && inhAttrs.Resources != nil {
+			res = inhAttrs.Resources.Clone().(types.Dict)
+		}
+		pageDict["Resources"] = res
+	}
+
+	d, err := pdfCtx.DereferenceDict(res[category])
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		d = types.NewDict()
+		res[category] = d
+	}
+
+	return d, nil
+}
+
+// wrapPageContent appends bb to the page content, saving and restoring the graphics state
+// around the existing content so bb starts from the default state.
+func wrapPageContent(pdfCtx *model.Context, pageDict types.Dict, bb []byte) error {
+	var contents types.Array
+
+	if o, found := pageDict.Find("Contents"); found {
+		c, err := pdfCtx.Dereference(o)
+		if err != nil {
+			return err
+		}
+		switch c := c.(type) {
+		case types.Array:
+			contents = append(contents, c...)
+		case types.StreamDict:
+			if _, ok := o.(types.IndirectRef); ok {
+				contents = append(contents, o)
+			} else {
+				indRef, err := pdfCtx.IndRefForNewObject(c)
+				if err != nil {
+					return err
+				}
+				contents = append(contents, *indRef)
+			}
+		}
+	}
+
+	newStream := func(b []byte) (*types.IndirectRef, error) {
+		sd, err := pdfCtx.NewStreamDictForBuf(b)
+		if err != nil {
+			return nil, err
+		}
+		if err := sd.Encode(); err != nil {
+			return nil, err
+		}
+		return pdfCtx.IndRefForNewObject(*sd)
+	}
+
+	before, err := newStream([]byte("q\n"))
+	if err != nil {
+		return err
+	}
+
+	after, err := newStream(append([]byte("\nQ\n"), bb...))
+	if err != nil {
+		return err
+	}
+
+	pageDict["Contents"] = append(append(types.Array{*before}, contents...), *after)
+
+	return nil
+}
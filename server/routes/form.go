@@ -0,0 +1,457 @@
+package routes
+
+import (
+	"fmt"
+	"os"
+	"pdftool/server/helper"
+	"pdftool/types"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v3"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/create"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/form"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/rs/zerolog/log"
+)
+
+// formField is one entry of the /v1/form/fields response. Value is a string, a bool
+// for checkboxes or a list of strings for list boxes.
+type formField struct {
+	ID      string       `json:"id"`
+	Name    string       `json:"name"`
+	Type    string       `json:"type"`
+	Value   any          `json:"value"`
+	Default any          `json:"default,omitempty"`
+	Options []string     `json:"options,omitempty"`
+	Locked  bool         `json:"locked"`
+	Pages   []int        `json:"pages"`
+	Widgets []formWidget `json:"widgets"`
+}
+
+// formWidget is where a field is shown: the page and its rect (llx, lly, urx, ury) in points.
+type formWidget struct {
+	Page int        `json:"page"`
+	Rect [4]float64 `json:"rect"`
+}
+
+// @Summary List form fields
+// @Description Returns every AcroForm field with its name, type, current value, options, pages and widget rects.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file"
+// @Param request body object false "JSON request with base64 PDF"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Success 200 {object} types.Response
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/form/fields [post]
+func FormFields(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "form",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	pdfCtx, readErr := readFormContext(result.InputPath, result.Password, model.EXPORTFORMFIELDS)
+	if readErr != nil {
+		log.Error().Err(readErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			"File is invalid, corrupted or the password is wrong.",
+		)
+	}
+
+	fields, fieldsErr := formFields(pdfCtx)
+	if fieldsErr != nil {
+		log.Error().Err(fieldsErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusInternalServerError,
+			helper.TransformPDFCPUErrorToResponse(fieldsErr),
+		)
+	}
+
+	return ctx.JSON(types.Response{
+		Error: false,
+		Data:  fields,
+	})
+}
+
+type formFillOptions struct {
+	Flatten bool           `json:"flatten" form:"flatten"`
+	Values  map[string]any `json:"values" form:"-"`
+}
+
+// @Summary Fill form fields
+// @Description Fills AcroForm fields from a JSON object of field name (or id) to value and optionally flattens the form,
+// @Description turning the fields into regular page content. Checkboxes take a bool, list boxes a list of strings.
+// @Description Locked fields are filled and stay locked.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce octet-stream
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file"
+// @Param request body object false "JSON request with base64 PDF and values object"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Param values formData string true "JSON object of field name to value"
+// @Param flatten formData bool false "Flatten the form after filling"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/form/fill [post]
+func FormFill(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "filled",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	var opts formFillOptions
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid form options")
+	}
+
+	values, valuesErr := formValues(ctx)
+	if valuesErr != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(valuesErr))
+	}
+	opts.Values = values
+
+	if stepErr := formFillPDF(result.InputPath, result.OutputPath, result.Password, opts); stepErr != nil {
+		return helper.SendErrorResponse(ctx, stepErr.code, stepErr.message)
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// readFormContext reads and validates inFile, decrypting it with password if needed.
+func readFormContext(inFile, password string, cmd model.CommandMode) (*model.Context, error) {
+	f, err := os.Open(inFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = cmd
+	conf.UserPW = password
+	conf.OwnerPW = password
+
+	return api.ReadValidateAndOptimize(f, conf)
+}
+
+// formValues reads the values object, sent as JSON in the body or as a JSON string
+// field in a multipart form.
+func formValues(ctx fiber.Ctx) (map[string]any, error) {
+	var values map[string]any
+	if strings.HasPrefix(ctx.Get("Content-Type"), "multipart/form-data") {
+		if value := ctx.FormValue("values"); value != "" {
+			if err := json.Unmarshal([]byte(value), &values); err != nil {
+				return nil, fmt.Errorf("invalid values: %v", err)
+			}
+		}
+	} else {
+		var request struct {
+			Values map[string]any `json:"values"`
+		}
+		if err := ctx.Bind().Body(&request); err != nil {
+			return nil, fmt.Errorf("invalid values: %v", err)
+		}
+		values = request.Values
+	}
+
+	return values, nil
+}
+
+// formFillPDF fills the form of inFile, opened with password, as opts tell and writes
+// outFile.
+func formFillPDF(inFile, outFile, password string, opts formFillOptions) *stepError {
+	if len(opts.Values) == 0 {
+		return newStepError(fiber.StatusBadRequest, "Values are required")
+	}
+
+	pdfCtx, readErr := readFormContext(inFile, password, model.FILLFORMFIELDS)
+	if readErr != nil {
+		log.Error().Err(readErr).Caller().Send()
+		return newStepError(fiber.StatusBadRequest, "File is invalid, corrupted or the password is wrong.")
+	}
+
+	if pdfCtx.Form == nil {
+		return newStepError(fiber.StatusBadRequest, "Document has no form")
+	}
+
+	if err := fillForm(pdfCtx, opts.Values); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return newStepError(fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(err))
+	}
+
+	if opts.Flatten {
+		if err := flattenForm(pdfCtx); err != nil {
+			log.Error().Err(err).Caller().Send()
+			return newStepError(fiber.StatusInternalServerError, helper.TransformPDFCPUErrorToResponse(err))
+		}
+	}
+
+	if err := api.WriteContextFile(pdfCtx, outFile); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return newStepError(fiber.StatusInternalServerError, helper.TransformPDFCPUErrorToResponse(err))
+	}
+
+	return nil
+}
+
+// formFields lists the fields of the form of pdfCtx, an empty list if there is none.
+func formFields(pdfCtx *model.Context) ([]formField, error) {
+	fields := []formField{}
+	if pdfCtx.Form == nil {
+		return fields, nil
+	}
+
+	formGroup, ok, err := form.ExportForm(pdfCtx.XRefTable, "")
+	if err != nil || !ok || len(formGroup.Forms) == 0 {
+		return fields, err
+	}
+
+	widgets, err := formWidgets(pdfCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	add := func(id, name, typ string, value, def any, options []string, locked bool, pages []int) {
+		// pdfcpu ids are the object numbers of the field hierarchy, the last one is the field itself
+		objNr := id[strings.LastIndex(id, ".")+1:]
+		fieldWidgets := widgets[objNr]
+		if fieldWidgets == nil {
+			fieldWidgets = []formWidget{}
+		}
+		fields = append(fields, formField{
+			ID:      id,
+			Name:    name,
+			Type:    typ,
+			Value:   value,
+			Default: def,
+			Options: options,
+			Locked:  locked,
+			Pages:   pages,
+			Widgets: fieldWidgets,
+		})
+	}
+
+	f := formGroup.Forms[0]
+	for _, tf := range f.TextFields {
+		add(tf.ID, tf.Name, "text", tf.Value, emptyAsNil(tf.Default), nil, tf.Locked, tf.Pages)
+	}
+	for _, df := range f.DateFields {
+		add(df.ID, df.Name, "date", df.Value, emptyAsNil(df.Default), nil, df.Locked, df.Pages)
+	}
+	for _, cb := range f.CheckBoxes {
+		add(cb.ID, cb.Name, "checkbox", cb.Value, cb.Default, nil, cb.Locked, cb.Pages)
+	}
+	for _, rb := range f.RadioButtonGroups {
+		add(rb.ID, rb.Name, "radio", rb.Value, emptyAsNil(rb.Default), rb.Options, rb.Locked, rb.Pages)
+	}
+	for _, cb := range f.ComboBoxes {
+		add(cb.ID, cb.Name, "combobox", cb.Value, emptyAsNil(cb.Default), cb.Options, cb.Locked, cb.Pages)
+	}
+	for _, lb := range f.ListBoxes {
+		values := lb.Values
+		if values == nil {
+			values = []string{}
+		}
+		var def any
+		if len(lb.Defaults) > 0 {
+			def = lb.Defaults
+		}
+		add(lb.ID, lb.Name, "listbox", values, def, lb.Options, lb.Locked, lb.Pages)
+	}
+
+	slices.SortStableFunc(fields, func(a, b formField) int {
+		if len(a.Pages) > 0 && len(b.Pages) > 0 && a.Pages[0] != b.Pages[0] {
+			return a.Pages[0] - b.Pages[0]
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return fields, nil
+}
+
+func emptyAsNil(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// formWidgets maps the object number of each field to its widget annotations. Like pdfcpu,
+// widgets of radio button groups and text field hierarchies belong to their parent field.
+func formWidgets(pdfCtx *model.Context) (map[string][]formWidget, error) {
+	widgets := map[string][]formWidget{}
+
+	for pageNr := 1; pageNr <= pdfCtx.PageCount; pageNr++ {
+		wAnnots, ok := pdfCtx.PageAnnots[pageNr][model.AnnWidget]
+		if !ok || wAnnots.IndRefs == nil {
+			continue
+		}
+
+		for _, indRef := range *wAnnots.IndRefs {
+			d, err := pdfCtx.DereferenceDict(indRef)
+			if err != nil {
+				return nil, err
+			}
+			if d == nil {
+				continue
+			}
+
+			key := indRef.ObjectNumber.String()
+			if parent := d.IndirectRefEntry("Parent"); parent != nil {
+				pd, err := pdfCtx.DereferenceDict(*parent)
+				if err != nil {
+					return nil, err
+				}
+				if ft := pd.NameEntry("FT"); ft != nil && (*ft == "Btn" || *ft == "Tx") {
+					key = parent.ObjectNumber.String()
+				}
+			}
+
+			rect, err := pdfCtx.RectForArray(d.ArrayEntry("Rect"))
+			if err != nil || rect == nil {
+				continue
+			}
+
+			widgets[key] = append(widgets[key], formWidget{
+				Page: pageNr,
+				Rect: [4]float64{rect.LL.X, rect.LL.Y, rect.UR.X, rect.UR.Y},
+			})
+		}
+	}
+
+	return widgets, nil
+}
+
+// fillForm sets the fields named in values, matched by fully qualified name or id.
+// Names that match no field are reported as an error. Filled fields keep their lock
+// state.
+func fillForm(pdfCtx *model.Context, values map[string]any) error {
+	// pdfcpu sets the lock state of the fields it fills to the one returned with their
+	// value, so the current one is looked up first
+	fields, err := formFields(pdfCtx)
+	if err != nil {
+		return err
+	}
+	locked := map[string]bool{}
+	for _, field := range fields {
+		locked[field.ID] = field.Locked
+	}
+
+	pdfCtx.RemoveSignature()
+
+	used := map[string]bool{}
+	fillDetails := func(id, name string, fieldType form.FieldType, format form.DataFormat) ([]string, bool, bool) {
+		key := name
+		v, ok := values[key]
+		if !ok {
+			key = id
+			if v, ok = values[key]; !ok {
+				return nil, false, false
+			}
+		}
+		used[key] = true
+		return formFieldValue(v, fieldType), locked[id], true
+	}
+
+	ok, pages, err := form.FillForm(pdfCtx, fillDetails, nil, form.JSON)
+	if err != nil {
+		return err
+	}
+
+	var unknown []string
+	for key := range values {
+		if !used[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return fmt.Errorf("unknown form fields: %s", strings.Join(unknown, ", "))
+	}
+
+	if !ok {
+		return api.ErrNoFormFieldsAffected
+	}
+
+	if _, _, err := create.UpdatePageTree(pdfCtx, pages, nil); err != nil {
+		return err
+	}
+
+	return api.ValidateContext(pdfCtx)
+}
+
+// formFieldValue converts a JSON value into the strings pdfcpu fills a field with.
+func formFieldValue(v any, fieldType form.FieldType) []string {
+	if fieldType == form.FTCheckBox {
+		checked := false
+		switch v := v.(type) {
+		case bool:
+			checked = v
+		case string:
+			checked = slices.Contains([]string{"true", "yes", "on", "1", "x"}, strings.ToLower(v))
+		case float64:
+			checked = v != 0
+		}
+		if checked {
+			return []string{"t"}
+		}
+		return []string{"f"}
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return []string{""}
+	case string:
+		return []string{v}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	case []any:
+		ss := make([]string, 0, len(v))
+		for _, e := range v {
+			ss = append(ss, fmt.Sprint(e))
+		}
+		return ss
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// flattenForm draws the field widgets into the page content and removes the form.
+func flattenForm(pdfCtx *model.Context) error {
+	if err := flattenAnnotations(pdfCtx, func(subtype string) bool { return subtype == "Widget" }); err != nil {
+		return err
+	}
+
+	removeForm(pdfCtx)
+
+	return nil
+}
+
+// removeForm drops the AcroForm dict, once its widgets are gone from the pages.
+func removeForm(pdfCtx *model.Context) {
+	delete(pdfCtx.RootDict, "AcroForm")
+	pdfCtx.Form = nil
+}
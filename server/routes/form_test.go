@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"fmt"
+	"pdftool/internal/pdftest"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func TestFillFormKeepsLocks(t *testing.T) {
+	var b pdftest.Builder
+	font := b.Add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	locked, open := b.Reserve(), b.Reserve()
+	root := b.Document(fmt.Sprintf("/MediaBox [0 0 200 200] /Annots [%d 0 R %d 0 R]", locked, open))
+	pages, page := root-2, root-1
+
+	field := "<< /Type /Annot /Subtype /Widget /FT /Tx /T (%s) /Ff %d /V (old) /Rect [10 %d 190 %d] /P %d 0 R /DA (/Helv 12 Tf 0 g) >>"
+	b.Set(locked, fmt.Sprintf(field, "locked", 1, 10, 30, page))
+	b.Set(open, fmt.Sprintf(field, "open", 0, 50, 70, page))
+	b.Set(root, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R /AcroForm << /Fields [%d 0 R %d 0 R] /DA (/Helv 0 Tf 0 g) "+
+		"/DR << /Font << /Helv %d 0 R >> >> >> >>", pages, locked, open, font))
+
+	pdfCtx, err := readFormContext(b.Write(t, root), "", model.FILLFORMFIELDS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fillForm(pdfCtx, map[string]any{"locked": "new", "open": "new"}); err != nil {
+		t.Fatal(err)
+	}
+
+	fields, err := formFields(pdfCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"locked": true, "open": false}
+	for _, field := range fields {
+		if field.Value != "new" {
+			t.Errorf("field %s has value %v, want new", field.Name, field.Value)
+		}
+		if field.Locked != want[field.Name] {
+			t.Errorf("field %s locked %v, want %v", field.Name, field.Locked, want[field.Name])
+		}
+	}
+	if len(fields) != len(want) {
+		t.Errorf("got %d fields, want %d", len(fields), len(want))
+	}
+}
@@ -0,0 +1,192 @@
+package routes
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"pdftool/server/helper"
+	"slices"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/rs/zerolog/log"
+)
+
+type imagesToPDFOptions struct {
+	PageSize string  `json:"page_size" form:"page_size"` // A4, A4L, Letter, ... or auto
+	Margin   float64 `json:"margin" form:"margin"`       // in points
+	Fit      string  `json:"fit" form:"fit"`             // contain or original
+	DPI      int     `json:"dpi" form:"dpi"`
+}
+
+// @Summary Convert images to PDF
+// @Description Creates a PDF with one JPEG, PNG, TIFF or WebP image per page, in the order given.
+// @Description With page_size "auto" every page takes the size of its image at the given DPI plus the margin.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce octet-stream
+// @Security ApiKeyAuth
+// @Param file formData file false "Images to convert (repeat the field for each image)"
+// @Param request body object false "JSON request with base64_images array"
+// @Param page_size formData string false "Page size: A4 (default), A4L, Letter, Legal, ... or auto"
+// @Param margin formData number false "Page margin in points"
+// @Param fit formData string false "contain (default) scales the image into the page, original keeps its size at the given DPI"
+// @Param dpi formData int false "Image resolution in dots per inch (default 72)"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/images-to-pdf [post]
+func ImagesToPDF(ctx fiber.Ctx) error {
+	result, err := helper.ProcessImageRequest(ctx, helper.PDFProcessOptions{
+		OutputPrefix: "images",
+		OutputExt:    ".pdf",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	var opts imagesToPDFOptions
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid image options")
+	}
+
+	if opts.Margin < 0 {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Margin cannot be negative")
+	}
+
+	if opts.DPI < 0 {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "DPI cannot be negative")
+	}
+
+	switch opts.Fit {
+	case "", "contain", "original":
+	default:
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid fit. Use contain or original")
+	}
+
+	if err := imagesToPDF(result.InputPaths, result.OutputPath, opts); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			helper.TransformPDFCPUErrorToResponse(err),
+		)
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// imagesToPDF builds a new PDF from imageFiles, one image per page.
+func imagesToPDF(imageFiles []string, outFile string, opts imagesToPDFOptions) error {
+	dpi := opts.DPI
+	if dpi == 0 {
+		dpi = 72
+	}
+
+	var pageDim *types.Dim
+	if opts.PageSize != "auto" {
+		pageSize := opts.PageSize
+		if pageSize == "" {
+			pageSize = "A4"
+		}
+
+		dim, _, err := types.ParsePageFormat(pageSize)
+		if err != nil {
+			return err
+		}
+
+		if 2*opts.Margin >= dim.Width || 2*opts.Margin >= dim.Height {
+			return fmt.Errorf("margin is too large for page size %s", pageSize)
+		}
+		pageDim = dim
+	}
+
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.IMPORTIMAGES
+
+	pdfCtx, err := pdfcpu.CreateContextWithXRefTable(conf, types.PaperSize["A4"])
+	if err != nil {
+		return err
+	}
+
+	pagesIndRef, err := pdfCtx.Pages()
+	if err != nil {
+		return err
+	}
+
+	pagesDict, err := pdfCtx.DereferenceDict(*pagesIndRef)
+	if err != nil {
+		return err
+	}
+
+	for _, imageFile := range imageFiles {
+		if err := addImagePage(pdfCtx, pagesIndRef, pagesDict, imageFile, pageDim, dpi, opts); err != nil {
+			return err
+		}
+	}
+
+	return api.WriteContextFile(pdfCtx, outFile)
+}
+
+func addImagePage(pdfCtx *model.Context, pagesIndRef *types.IndirectRef, pagesDict types.Dict, imageFile string, pageDim *types.Dim, dpi int, opts imagesToPDFOptions) error {
+	f, err := os.Open(imageFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return fmt.Errorf("unsupported image: %v", err)
+	}
+	if !slices.Contains([]string{"jpeg", "png", "tiff", "webp"}, format) {
+		return fmt.Errorf("unsupported image format: %s", format)
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+
+	imageWidth := float64(cfg.Width) * 72 / float64(dpi)
+	imageHeight := float64(cfg.Height) * 72 / float64(dpi)
+
+	imp := pdfcpu.DefaultImportConfig()
+	imp.Pos = types.Center
+	imp.DPI = dpi
+
+	if pageDim == nil {
+		// Page takes the size of the image plus margins
+		imp.PageDim = &types.Dim{Width: imageWidth + 2*opts.Margin, Height: imageHeight + 2*opts.Margin}
+		imp.Scale = 1
+		imp.ScaleAbs = true
+	} else {
+		imp.PageDim = pageDim
+		if opts.Fit == "original" {
+			imp.Scale = 1
+			imp.ScaleAbs = true
+		} else {
+			imp.Scale = min((pageDim.Width-2*opts.Margin)/pageDim.Width, (pageDim.Height-2*opts.Margin)/pageDim.Height)
+		}
+	}
+
+	indRef, err := pdfcpu.NewPageForImage(pdfCtx.XRefTable, f, pagesIndRef, imp)
+	if err != nil {
+		return err
+	}
+
+	if err := pdfCtx.SetValid(*indRef); err != nil {
+		return err
+	}
+
+	if err := model.AppendPageTree(indRef, 1, pagesDict); err != nil {
+		return err
+	}
+
+	pdfCtx.PageCount++
+	return nil
+}
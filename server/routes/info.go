@@ -0,0 +1,167 @@
+package routes
+
+import (
+	"errors"
+	"os"
+	"pdftool/server/helper"
+	"pdftool/types"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/rs/zerolog/log"
+)
+
+type pageSize struct {
+	Page   int     `json:"page"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// documentInfo is the data of the /v1/info response. Page sizes are in points.
+type documentInfo struct {
+	Version          string            `json:"version,omitempty"`
+	PageCount        int               `json:"page_count"`
+	PageSizes        []pageSize        `json:"page_sizes,omitempty"`
+	Encrypted        bool              `json:"encrypted"`
+	PasswordRequired bool              `json:"password_required"`
+	Permissions      []string          `json:"permissions"`
+	Title            string            `json:"title"`
+	Author           string            `json:"author"`
+	Subject          string            `json:"subject"`
+	Keywords         []string          `json:"keywords"`
+	Creator          string            `json:"creator"`
+	Producer         string            `json:"producer"`
+	CreationDate     string            `json:"creation_date"`
+	ModificationDate string            `json:"modification_date"`
+	Properties       map[string]string `json:"properties"`
+	XMP              bool              `json:"xmp"`
+	Linearized       bool              `json:"linearized"`
+	Tagged           bool              `json:"tagged"`
+	Form             bool              `json:"form"`
+	Signatures       bool              `json:"signatures"`
+	Attachments      int               `json:"attachments"`
+}
+
+// @Summary Inspect a PDF file
+// @Description Returns page count, page sizes, version, encryption status, permissions, document properties,
+// @Description XMP presence, linearization, tagged status and attachment count.
+// @Description Encrypted files that need a password are reported with password_required instead of failing.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file to inspect"
+// @Param request body object false "JSON request with base64 PDF"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Success 200 {object} types.Response
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/info [post]
+func Info(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "info",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	info, infoErr := pdfInfo(result.InputPath, result.Password)
+	if infoErr != nil {
+		log.Error().Err(infoErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			"File is invalid or corrupted. Please upload a valid PDF.",
+		)
+	}
+
+	return ctx.JSON(types.Response{
+		Error: false,
+		Data:  info,
+	})
+}
+
+// pdfInfo reads inFile, decrypting it with password if needed.
+func pdfInfo(inFile, password string) (*documentInfo, error) {
+	f, err := os.Open(inFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.LISTINFO
+	conf.ValidationMode = model.ValidationRelaxed
+	conf.UserPW = password
+	conf.OwnerPW = password
+
+	pdfCtx, err := api.ReadAndValidate(f, conf)
+	if errors.Is(err, pdfcpu.ErrWrongPassword) {
+		return &documentInfo{Encrypted: true, PasswordRequired: true, Permissions: []string{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pdfcpu.DetectWatermarks(pdfCtx); err != nil {
+		return nil, err
+	}
+
+	info, err := pdfcpu.Info(pdfCtx, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	dims, err := pdfCtx.PageDims()
+	if err != nil {
+		return nil, err
+	}
+
+	pageSizes := make([]pageSize, len(dims))
+	for i, dim := range dims {
+		pageSizes[i] = pageSize{Page: i + 1, Width: dim.Width, Height: dim.Height}
+	}
+
+	keywords := info.Keywords
+	if keywords == nil {
+		keywords = []string{}
+	}
+
+	permissions := []string{"all"}
+	if info.Encrypted {
+		permissions = permissionNames(info.Permissions)
+	}
+
+	var xmp bool
+	if rootDict, err := pdfCtx.Catalog(); err == nil {
+		_, xmp = rootDict.Find("Metadata")
+	}
+
+	return &documentInfo{
+		Version:          info.Version,
+		PageCount:        info.PageCount,
+		PageSizes:        pageSizes,
+		Encrypted:        info.Encrypted,
+		Permissions:      permissions,
+		Title:            info.Title,
+		Author:           info.Author,
+		Subject:          info.Subject,
+		Keywords:         keywords,
+		Creator:          info.Creator,
+		Producer:         info.Producer,
+		CreationDate:     info.CreationDate,
+		ModificationDate: info.ModificationDate,
+		Properties:       info.Properties,
+		XMP:              xmp,
+		Linearized:       info.Linearized,
+		Tagged:           info.Tagged,
+		Form:             info.Form,
+		Signatures:       info.Signatures,
+		Attachments:      len(info.Attachments),
+	}, nil
+}
@@ -0,0 +1,185 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"pdftool/types"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+)
+
+// Callbacks are tried this many times in all, the first retry after callbackBackoff
+// and each next one after twice as long as the one before.
+const (
+	callbackAttempts = 6
+	callbackBackoff  = 2 * time.Second
+	callbackTimeout  = 30 * time.Second
+)
+
+var errCallbackAddress = errors.New("callback address is not public")
+
+// Networks callbacks are refused besides the loopback, private, link-local and multicast
+// ones: this network, shared address space, IETF protocol assignments, benchmarking,
+// reserved and broadcast addresses, and NAT64, which embeds IPv4 addresses.
+var reservedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// callbackClient posts callbacks. Its dialer checks each address it connects to, so the
+// addresses a host resolves to when posting, and those of redirects, are checked too. It
+// connects directly, a proxy would be the address checked instead.
+var callbackClient = &http.Client{
+	Timeout: callbackTimeout,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 10 * time.Second, Control: dialCallback}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		ForceAttemptHTTP2:   true,
+	},
+}
+
+// dialCallback refuses to connect to addresses callbackAddressAllowed does not allow.
+func dialCallback(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !callbackAddressAllowed(ip) {
+		return fmt.Errorf("%w: %s", errCallbackAddress, ip)
+	}
+	return nil
+}
+
+// checkCallbackHost resolves host and fails with errCallbackAddress if an address it
+// resolves to is not allowed. It only catches mistakes early, the host may resolve
+// differently once the callback is posted.
+func checkCallbackHost(ctx context.Context, host string) error {
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if !callbackAddressAllowed(ip) {
+			return fmt.Errorf("%w: %s", errCallbackAddress, ip)
+		}
+	}
+	return nil
+}
+
+// callbackAddressAllowed reports whether callbacks may connect to ip: a public address, or
+// one in the networks of JOB_CALLBACK_NETWORKS.
+func callbackAddressAllowed(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	for _, network := range types.Config.Jobs.CallbackNetworks {
+		if prefix, err := netip.ParsePrefix(strings.TrimSpace(network)); err == nil && prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedNetworks {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// notifyJob posts the finished job j to its callback URL as a types.Response, signed as
+// signCallback describes.
+func notifyJob(j job) {
+	body, err := json.Marshal(types.Response{
+		Error:   j.Status == jobFailed,
+		Message: j.Error,
+		Data:    j,
+	})
+	if err != nil {
+		log.Error().Err(err).Caller().Send()
+		return
+	}
+
+	backoff := callbackBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := postCallback(callbackClient, j.callbackURL, j.ID, body)
+		if err == nil {
+			log.Debug().Str("job", j.ID).Msg("job callback delivered")
+			return
+		}
+		if !retry || attempt == callbackAttempts {
+			log.Error().Err(err).Str("job", j.ID).Int("attempts", attempt).Msg("failed to deliver job callback")
+			return
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// signCallback returns the signature of a callback body sent at timestamp, in Unix
+// seconds: the hex HMAC-SHA256 keyed with the webhook secret of timestamp, a dot and the
+// body, as sha256=<hex>. It is sent in the X-Signature-256 header and timestamp in the
+// X-Timestamp header. Receivers compute the same HMAC of the header and the raw body,
+// compare it in constant time and refuse callbacks whose timestamp is more than a few
+// minutes off, so a captured callback can not be replayed later.
+func signCallback(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(types.Config.Keys.Webhook))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// postCallback posts body to callbackURL, signed at the time of this attempt, and reports
+// whether to try again if it fails. Network errors, rate limits and server errors are worth
+// another try, addresses that are not allowed are not.
+func postCallback(client *http.Client, callbackURL, jobID string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", types.AppName)
+	req.Header.Set("X-Job-Id", jobID)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set("X-Signature-256", signCallback(timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return !errors.Is(err, errCallbackAddress), fmt.Errorf("failed to get response: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("callback failed with status %d", resp.StatusCode)
+	}
+	return false, fmt.Errorf("callback failed with status %d", resp.StatusCode)
+}
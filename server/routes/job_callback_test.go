@@ -0,0 +1,100 @@
+package routes
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"pdftool/types"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCallbackAddressAllowed(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.215.14":        true,
+		"2606:2800:21f:cb07::": true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"0.1.2.3":              false,
+		"224.0.0.1":            false,
+		"255.255.255.255":      false,
+		"::ffff:127.0.0.1":     false,
+		"64:ff9b::a00:1":       false,
+	}
+	for addr, want := range tests {
+		if got := callbackAddressAllowed(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("callbackAddressAllowed(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestPostCallbackPrivate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if err := checkCallbackHost(context.Background(), "localhost"); !errors.Is(err, errCallbackAddress) {
+		t.Errorf("got error %v checking localhost, want %v", err, errCallbackAddress)
+	}
+	retry, err := postCallback(callbackClient, server.URL, "job", []byte("{}"))
+	if !errors.Is(err, errCallbackAddress) || retry {
+		t.Errorf("got error %v and retry %v posting to a loopback server", err, retry)
+	}
+
+	// Networks JOB_CALLBACK_NETWORKS lists are reachable
+	types.Config.Jobs.CallbackNetworks = []string{"127.0.0.1/32"}
+	defer func() { types.Config.Jobs.CallbackNetworks = nil }()
+	if _, err := postCallback(callbackClient, server.URL, "job", []byte("{}")); err != nil {
+		t.Errorf("posting to an allowed network: %v", err)
+	}
+}
+
+func TestPostCallbackSignature(t *testing.T) {
+	types.Config.Keys.Webhook = "secret"
+	types.Config.Jobs.CallbackNetworks = []string{"127.0.0.1/32"}
+	defer func() {
+		types.Config.Keys.Webhook = ""
+		types.Config.Jobs.CallbackNetworks = nil
+	}()
+
+	// The receiver verifies the callback as the job API describes it
+	var verified bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get("X-Timestamp")
+		sent, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil || time.Since(time.Unix(sent, 0)).Abs() > 5*time.Minute {
+			t.Errorf("got timestamp %q", timestamp)
+		}
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(timestamp + "." + string(body)))
+		verified = hmac.Equal([]byte(r.Header.Get("X-Signature-256")), []byte("sha256="+hex.EncodeToString(mac.Sum(nil))))
+	}))
+	defer server.Close()
+
+	if _, err := postCallback(callbackClient, server.URL, "job", []byte(`{"error":false}`)); err != nil {
+		t.Fatal(err)
+	}
+	if !verified {
+		t.Error("signature of the callback does not verify")
+	}
+
+	// A body replayed with another timestamp does not verify
+	if signCallback("1", []byte(`{"error":false}`)) == signCallback("2", []byte(`{"error":false}`)) {
+		t.Error("signature does not depend on the timestamp")
+	}
+}
@@ -0,0 +1,465 @@
+package routes
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"pdftool/server/helper"
+	"pdftool/types"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/keyauth"
+	"github.com/gofiber/fiber/v3/middleware/recover"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+// Job states.
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobCompleted = "completed"
+	jobFailed    = "failed"
+)
+
+// JobOperation is an operation that can run as a job, with the time it may take. With
+// 0, jobs of the operation are limited by the configured job timeout.
+type JobOperation struct {
+	Handler fiber.Handler
+	Timeout time.Duration
+}
+
+// job is an operation run in the background. Its fields are guarded by jobQueue.mu.
+type job struct {
+	ID         string     `json:"id"`
+	Operation  string     `json:"operation"`
+	Status     string     `json:"status"`
+	Progress   int        `json:"progress"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ResultURL  string     `json:"result_url,omitempty"`
+
+	// Hash of the credential that created the job, see jobOwner
+	owner string
+	// The request, its body spooled to requestPath until the job runs
+	contentType string
+	requestPath string
+	// Where the job is posted to once finished, see notifyJob
+	callbackURL string
+
+	// The response of a completed job, its body stored in resultPath
+	resultPath string
+	resultType string
+	resultName string
+}
+
+// jobQueue runs jobs on a fixed number of workers, through an app serving the operations.
+type jobQueue struct {
+	handler    fasthttp.RequestHandler
+	operations map[string]JobOperation
+	pending    chan *job
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+var jobs *jobQueue
+
+type (
+	// jobContextKey is the request local holding the context a job runs with
+	jobContextKey struct{}
+	// jobKey is the context value holding the running job
+	jobKey struct{}
+)
+
+// StartJobs starts the workers running jobs of operations, by name. Requests to the
+// operations are handled by an app made with config.
+func StartJobs(config fiber.Config, operations map[string]JobOperation) {
+	app := fiber.New(config)
+	app.Use(recover.New(recover.Config{EnableStackTrace: true}))
+	app.Use(func(ctx fiber.Ctx) error {
+		if jobCtx, ok := ctx.Locals(jobContextKey{}).(context.Context); ok {
+			ctx.SetContext(jobCtx)
+		}
+		return ctx.Next()
+	})
+	for name, operation := range operations {
+		app.Post("/"+name, operation.Handler)
+	}
+
+	jobs = &jobQueue{
+		handler:    app.Handler(),
+		operations: operations,
+		pending:    make(chan *job, max(types.Config.Jobs.QueueSize, 1)),
+		jobs:       map[string]*job{},
+	}
+	for range max(types.Config.Jobs.Workers, 1) {
+		go jobs.work()
+	}
+	go jobs.sweep()
+}
+
+// @Summary Create a job
+// @Description Queues an operation to run in the background and returns the job, whose status is polled at
+// @Description /v1/jobs/{id} and whose output is downloaded from /v1/jobs/{id}/result once completed. The request
+// @Description is the one of the operation, multipart form or JSON, with the operation name added as operation,
+// @Description e.g. encrypt, decrypt, repair, optimize or ocr. With callback_url, the job is posted there once
+// @Description finished. The X-Timestamp header holds the Unix time it was sent at and X-Signature-256 holds
+// @Description sha256= and the hex HMAC-SHA256, keyed with WEBHOOK_SECRET, of the timestamp, a dot and the raw
+// @Description body. Receivers check both and refuse timestamps more than a few minutes old. A job running
+// @Description longer than its operation allows, or than JOB_TIMEOUT for operations without a limit, fails.
+// @Tags Jobs
+// @Accept multipart/form-data,application/json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param operation formData string true "Operation to run, the path of its endpoint without /v1/"
+// @Param callback_url formData string false "URL the finished job is posted to, on a public host unless JOB_CALLBACK_NETWORKS allows it"
+// @Param file formData file false "PDF file for the operation"
+// @Param request body object false "JSON request of the operation with its operation name"
+// @Success 202 {object} types.Response
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 503 {object} types.Response
+// @Router /v1/jobs [post]
+func CreateJob(ctx fiber.Ctx) error {
+	var opts struct {
+		Operation   string `json:"operation" form:"operation"`
+		CallbackURL string `json:"callback_url" form:"callback_url"`
+	}
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid job request")
+	}
+
+	return enqueueJob(ctx, strings.Trim(strings.ToLower(opts.Operation), "/"), opts.CallbackURL)
+}
+
+// enqueueJob queues the request of ctx as a job of operation name, posted to callbackURL
+// once finished if set, and responds with the job.
+func enqueueJob(ctx fiber.Ctx, name, callbackURL string) error {
+	if _, ok := jobs.operations[name]; !ok {
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			fmt.Sprintf("Unknown operation, use one of %s", strings.Join(slices.Sorted(maps.Keys(jobs.operations)), ", ")),
+		)
+	}
+
+	if callbackURL != "" {
+		if types.Config.Keys.Webhook == "" {
+			return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Callbacks are disabled, no webhook secret is configured")
+		}
+		u, err := url.Parse(callbackURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid callback_url, use an absolute http or https URL")
+		}
+		if err := checkCallbackHost(ctx.Context(), u.Hostname()); err != nil {
+			if errors.Is(err, errCallbackAddress) {
+				return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid callback_url, the host is not public")
+			}
+			return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid callback_url, the host can not be resolved")
+		}
+	}
+
+	id := rand.Text()
+	j := &job{
+		ID:          id,
+		Operation:   name,
+		Status:      jobQueued,
+		CreatedAt:   time.Now(),
+		owner:       jobOwner(ctx),
+		contentType: ctx.Get(fiber.HeaderContentType),
+		requestPath: filepath.Join("/tmp", "job-"+id+"-request"),
+		callbackURL: callbackURL,
+	}
+	if err := os.WriteFile(j.requestPath, ctx.Body(), 0o600); err != nil {
+		log.Error().Err(err).Caller().Send()
+		os.Remove(j.requestPath)
+		return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, "Failed to store job request")
+	}
+
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+
+	select {
+	case jobs.pending <- j:
+	default:
+		os.Remove(j.requestPath)
+		return helper.SendErrorResponse(ctx, fiber.StatusServiceUnavailable, "Too many jobs queued, try again later")
+	}
+	jobs.jobs[j.ID] = j
+
+	return ctx.Status(fiber.StatusAccepted).JSON(types.Response{
+		Error: false,
+		Data:  *j,
+	})
+}
+
+// @Summary Get a job
+// @Description Returns the status of a job: queued, running, completed or failed, with its progress in percent
+// @Description and, if it failed, the error. Jobs are found with the API key or in the session that created them.
+// @Tags Jobs
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Job ID"
+// @Success 200 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 404 {object} types.Response
+// @Router /v1/jobs/{id} [get]
+func JobStatus(ctx fiber.Ctx) error {
+	status, ok := ownJob(ctx)
+	if !ok {
+		return helper.SendErrorResponse(ctx, fiber.StatusNotFound, "Job not found")
+	}
+
+	return ctx.JSON(types.Response{
+		Error: false,
+		Data:  status,
+	})
+}
+
+// @Summary Download a job result
+// @Description Returns the output of a completed job, as the operation itself would have responded. Results are
+// @Description found with the API key or in the session that created the job.
+// @Tags Jobs
+// @Produce json,application/pdf,application/zip
+// @Security ApiKeyAuth
+// @Param id path string true "Job ID"
+// @Success 200 {file} binary
+// @Failure 401 {object} types.Response
+// @Failure 404 {object} types.Response
+// @Failure 409 {object} types.Response
+// @Router /v1/jobs/{id}/result [get]
+func JobResult(ctx fiber.Ctx) error {
+	status, ok := ownJob(ctx)
+	switch {
+	case !ok:
+		return helper.SendErrorResponse(ctx, fiber.StatusNotFound, "Job not found")
+	case status.Status == jobFailed:
+		return helper.SendErrorResponse(ctx, fiber.StatusConflict, "Job failed: "+status.Error)
+	case status.Status != jobCompleted:
+		return helper.SendErrorResponse(ctx, fiber.StatusConflict, "Job is "+status.Status)
+	}
+
+	f, err := os.Open(status.resultPath)
+	if err != nil {
+		log.Error().Err(err).Caller().Send()
+		return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, "Failed to read job result")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		log.Error().Err(err).Caller().Send()
+		return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, "Failed to read job result")
+	}
+
+	if status.resultName != "" {
+		ctx.Attachment(status.resultName)
+	}
+	ctx.Set(fiber.HeaderContentType, status.resultType)
+	return ctx.SendStream(f, int(info.Size()))
+}
+
+// ownJob returns a copy of the job of the id parameter of ctx, if it was created by the
+// owner of the request. Jobs of others are not found.
+func ownJob(ctx fiber.Ctx) (job, bool) {
+	owner := jobOwner(ctx)
+
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+	j, ok := jobs.jobs[ctx.Params("id")]
+	if !ok || subtle.ConstantTimeCompare([]byte(j.owner), []byte(owner)) != 1 {
+		return job{}, false
+	}
+	return *j, true
+}
+
+// jobOwner identifies who makes the request of ctx, by the API key it is authorized with
+// or, for a signed in user, by the session. Only a hash of the credential is kept.
+func jobOwner(ctx fiber.Ctx) string {
+	credential := "key:" + keyauth.TokenFromContext(ctx)
+	if credential == "key:" && SessionStore != nil {
+		if sess, err := SessionStore.Get(ctx); err == nil {
+			credential = "session:" + sess.ID()
+			sess.Release()
+		}
+	}
+	sum := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(sum[:])
+}
+
+// runningAsJob reports whether the request of ctx is run by a job.
+func runningAsJob(ctx fiber.Ctx) bool {
+	_, ok := ctx.Locals(jobContextKey{}).(context.Context)
+	return ok
+}
+
+// reportJobProgress records that done of total steps of the job running with ctx are
+// complete. Outside of jobs it does nothing.
+func reportJobProgress(ctx context.Context, done, total int) {
+	j, ok := ctx.Value(jobKey{}).(*job)
+	if !ok || total <= 0 {
+		return
+	}
+
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+	if j.Status != jobRunning {
+		return
+	}
+	// 100 is left for the job to complete
+	j.Progress = min(100*done/total, 99)
+}
+
+func (q *jobQueue) work() {
+	for j := range q.pending {
+		q.run(j)
+	}
+}
+
+// run sends the request of j to its operation and keeps the response. The worker is busy
+// until the operation returns, also once the job timed out, so no more operations run at
+// once than there are workers.
+func (q *jobQueue) run(j *job) {
+	q.mu.Lock()
+	startedAt := time.Now()
+	j.Status, j.StartedAt = jobRunning, &startedAt
+	requestPath := j.requestPath
+	j.requestPath = ""
+	q.mu.Unlock()
+
+	body, err := os.ReadFile(requestPath)
+	os.Remove(requestPath)
+	if err != nil {
+		log.Error().Err(err).Caller().Send()
+		q.finish(j, jobFailed, "Failed to read job request")
+		return
+	}
+	var req fasthttp.Request
+	req.Header.SetMethod(fiber.MethodPost)
+	req.SetRequestURI("/" + j.Operation)
+	req.Header.SetContentType(j.contentType)
+	req.SetBody(body)
+
+	jobCtx := context.WithValue(context.Background(), jobKey{}, j)
+	timeout := q.operations[j.Operation].Timeout
+	if timeout <= 0 {
+		timeout = types.Config.Jobs.Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		jobCtx, cancel = context.WithTimeout(jobCtx, timeout)
+		defer cancel()
+	}
+
+	var reqCtx fasthttp.RequestCtx
+	reqCtx.Init(&req, nil, nil)
+	reqCtx.SetUserValue(jobContextKey{}, jobCtx)
+	resp := &reqCtx.Response
+
+	// Operations stop once they notice the deadline, whatever they answer then the job
+	// timed out
+	q.handler(&reqCtx)
+	defer resp.CloseBodyStream() //nolint:errcheck
+	if errors.Is(jobCtx.Err(), context.DeadlineExceeded) {
+		q.finish(j, jobFailed, "Job timed out")
+		return
+	}
+
+	if code := resp.StatusCode(); code >= fiber.StatusBadRequest {
+		message := fmt.Sprintf("Operation failed with status %d", code)
+		var body types.Response
+		if err := json.Unmarshal(resp.Body(), &body); err == nil && body.Message != "" {
+			message = body.Message
+		}
+		q.finish(j, jobFailed, message)
+		return
+	}
+
+	resultPath := filepath.Join("/tmp", "job-"+j.ID)
+	if err := writeJobResult(resultPath, resp); err != nil {
+		log.Error().Err(err).Caller().Send()
+		os.Remove(resultPath)
+		q.finish(j, jobFailed, "Failed to store job result")
+		return
+	}
+
+	q.mu.Lock()
+	j.resultPath = resultPath
+	j.resultType = string(resp.Header.ContentType())
+	if _, params, err := mime.ParseMediaType(string(resp.Header.Peek(fiber.HeaderContentDisposition))); err == nil {
+		j.resultName = params["filename"]
+	}
+	q.mu.Unlock()
+
+	q.finish(j, jobCompleted, "")
+}
+
+func writeJobResult(path string, resp *fasthttp.Response) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := resp.BodyWriteTo(f); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// finish records that j finished with status and message, once. A job finishing again
+// keeps its first status.
+func (q *jobQueue) finish(j *job, status, message string) {
+	q.mu.Lock()
+	if j.FinishedAt != nil {
+		q.mu.Unlock()
+		return
+	}
+	finishedAt := time.Now()
+	j.Status, j.Error, j.FinishedAt = status, message, &finishedAt
+	if status == jobCompleted {
+		j.Progress = 100
+		j.ResultURL = strings.TrimSuffix(types.Config.App.BaseURL, "/") + "/v1/jobs/" + j.ID + "/result"
+	}
+	finished := *j
+	q.mu.Unlock()
+
+	if finished.callbackURL != "" {
+		go notifyJob(finished)
+	}
+}
+
+// sweep removes finished jobs and their results once they are kept for long enough.
+func (q *jobQueue) sweep() {
+	for range time.Tick(time.Minute) {
+		q.mu.Lock()
+		for id, j := range q.jobs {
+			if j.FinishedAt == nil || time.Since(*j.FinishedAt) < types.Config.Jobs.Retention {
+				continue
+			}
+			if j.resultPath != "" {
+				if err := os.Remove(j.resultPath); err != nil {
+					log.Warn().Err(err).Str("job", id).Msg("failed to remove job result")
+				}
+			}
+			delete(q.jobs, id)
+		}
+		q.mu.Unlock()
+	}
+}
@@ -0,0 +1,150 @@
+package routes
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"pdftool/types"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/keyauth"
+)
+
+func TestJobTimeout(t *testing.T) {
+	defaultTimeout := types.Config.Jobs.Timeout
+	types.Config.Jobs.Timeout = 50 * time.Millisecond
+	defer func() { types.Config.Jobs.Timeout = defaultTimeout }()
+
+	for name, timeout := range map[string]time.Duration{
+		"operation timeout": 50 * time.Millisecond,
+		"default timeout":   0,
+	} {
+		t.Run(name, func(t *testing.T) {
+			q := &jobQueue{
+				operations: map[string]JobOperation{"slow": {Timeout: timeout}},
+				jobs:       map[string]*job{},
+			}
+			j := &job{ID: "slow", Operation: "slow", Status: jobQueued, requestPath: writeTestJobRequest(t, "")}
+
+			// The operation takes a while to stop once it notices the deadline, the job
+			// runs until it does
+			var statusAtDeadline string
+			app := fiber.New()
+			app.Use(func(ctx fiber.Ctx) error {
+				ctx.SetContext(ctx.Locals(jobContextKey{}).(context.Context))
+				return ctx.Next()
+			})
+			app.Post("/slow", func(ctx fiber.Ctx) error {
+				<-ctx.Context().Done()
+				time.Sleep(100 * time.Millisecond)
+				q.mu.Lock()
+				statusAtDeadline = j.Status
+				q.mu.Unlock()
+				return ctx.SendString("too late")
+			})
+			q.handler = app.Handler()
+
+			start := time.Now()
+			q.run(j)
+			if d := time.Since(start); d < 150*time.Millisecond {
+				t.Errorf("worker returned after %v, before the operation", d)
+			}
+			if statusAtDeadline != jobRunning {
+				t.Errorf("got status %q while the operation stops, want running", statusAtDeadline)
+			}
+			if j.Status != jobFailed || j.Error != "Job timed out" {
+				t.Errorf("got status %q with error %q, want failed with a timeout", j.Status, j.Error)
+			}
+		})
+	}
+}
+
+func TestJobOwner(t *testing.T) {
+	defaultJobs := jobs
+	defer func() { jobs = defaultJobs }()
+
+	operations := fiber.New()
+	operations.Post("/echo", func(ctx fiber.Ctx) error {
+		return ctx.Send(ctx.Body())
+	})
+	jobs = &jobQueue{
+		handler:    operations.Handler(),
+		operations: map[string]JobOperation{"echo": {}},
+		pending:    make(chan *job, 1),
+		jobs:       map[string]*job{},
+	}
+
+	app := fiber.New()
+	app.Use(keyauth.New(keyauth.Config{
+		Validator: func(_ fiber.Ctx, key string) (bool, error) {
+			return key == "first" || key == "second", nil
+		},
+	}))
+	app.Post("/jobs", CreateJob)
+	app.Get("/jobs/:id", JobStatus)
+	app.Get("/jobs/:id/result", JobResult)
+
+	request := func(method, path, key, body string) (int, string) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+key)
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		content, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(content)
+	}
+
+	body := `{"operation": "echo"}`
+	code, content := request(fiber.MethodPost, "/jobs", "first", body)
+	var created struct {
+		Data job `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(content), &created); code != fiber.StatusAccepted || err != nil {
+		t.Fatalf("creating job: %d %s", code, content)
+	}
+	id := created.Data.ID
+
+	// The request waits on disk until the job runs
+	j := <-jobs.pending
+	if spooled, err := os.ReadFile(j.requestPath); err != nil || string(spooled) != body {
+		t.Errorf("got spooled request %q, error %v", spooled, err)
+	}
+	requestPath := j.requestPath
+	jobs.run(j)
+	if _, err := os.Stat(requestPath); !os.IsNotExist(err) {
+		t.Errorf("spooled request left after the job ran: %v", err)
+	}
+	defer os.Remove(j.resultPath)
+
+	for _, path := range []string{"/jobs/" + id, "/jobs/" + id + "/result"} {
+		if code, content := request(fiber.MethodGet, path, "second", ""); code != fiber.StatusNotFound {
+			t.Errorf("GET %s with another key: %d %s", path, code, content)
+		}
+	}
+	if code, content := request(fiber.MethodGet, "/jobs/"+id, "first", ""); code != fiber.StatusOK || !strings.Contains(content, jobCompleted) {
+		t.Errorf("GET job with its key: %d %s", code, content)
+	}
+	if code, content := request(fiber.MethodGet, "/jobs/"+id+"/result", "first", ""); code != fiber.StatusOK || content != body {
+		t.Errorf("GET result with its key: %d %s", code, content)
+	}
+}
+
+// writeTestJobRequest spools the request body of a test job and returns its path.
+func writeTestJobRequest(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "request")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
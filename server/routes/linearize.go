@@ -0,0 +1,604 @@
+package routes
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+var errLinearizeEncrypted = errors.New("encrypted files can not be linearized")
+
+// linearizeLayout holds the object numbers of the input in the sections of a linearized file
+// (PDF 32000-1:2008, annex F), each in the order it is written.
+type linearizeLayout struct {
+	docOpen   []int   // part 4: catalog and the objects opening the document needs
+	firstPage []int   // part 6: first page object and every object the first page uses
+	pages     [][]int // part 7: per remaining page, its page object and the objects only it uses
+	shared    []int   // part 8: objects used by several of the remaining pages
+	other     []int   // part 9: everything else
+	// per page, the objects of the first page and shared sections it uses
+	pageShared [][]int
+}
+
+// writeLinearizedFile writes pdfCtx to outFile as a linearized PDF, with the first page in
+// front and hint tables telling viewers where each page is, so they can show the first page
+// before the download completes. Objects are written uncompressed with classic xref tables.
+func writeLinearizedFile(pdfCtx *model.Context, outFile string) error {
+	if pdfCtx.Encrypt != nil {
+		return errLinearizeEncrypted
+	}
+	if pdfCtx.PageCount == 0 || pdfCtx.Root == nil {
+		return fmt.Errorf("document has no pages")
+	}
+
+	layout, err := linearizeObjects(pdfCtx)
+	if err != nil {
+		return err
+	}
+
+	// Parts 7 to 9 are numbered from 1, followed by the linearization dict, part 4, the hint
+	// stream and part 6, which make up the first page cross-reference section.
+	numbers := map[int]int{}
+	next := 1
+	number := func(objNrs []int) {
+		for _, objNr := range objNrs {
+			numbers[objNr] = next
+			next++
+		}
+	}
+	for _, page := range layout.pages {
+		number(page)
+	}
+	number(layout.shared)
+	number(layout.other)
+	linNr := next
+	next++
+	number(layout.docOpen)
+	hintNr := next
+	next++
+	number(layout.firstPage)
+	size := next
+
+	serialized := map[int][]byte{}
+	for objNr, newNr := range numbers {
+		b, err := linearizedObject(pdfCtx, objNr, newNr, numbers)
+		if err != nil {
+			return err
+		}
+		serialized[objNr] = b
+	}
+
+	length := func(objNrs []int) (n int64) {
+		for _, objNr := range objNrs {
+			n += int64(len(serialized[objNr]))
+		}
+		return n
+	}
+
+	header := []byte("%PDF-" + pdfCtx.HeaderVersion.String() + "\n%\xe2\xe3\xcf\xd3\n")
+
+	// The linearization dict and first page trailer hold offsets only known at the end,
+	// numbers are padded to a fixed width so their length does not depend on them.
+	linDict := func(l, hintOffset, hintLength, e, t int64) []byte {
+		return fmt.Appendf(nil, "%d 0 obj\n<< /Linearized 1 /L %-10d /H [ %-10d %-10d ] /O %-10d /E %-10d /N %-10d /T %-10d >>\nendobj\n",
+			linNr, l, hintOffset, hintLength, numbers[layout.firstPage[0]], e, pdfCtx.PageCount, t)
+	}
+
+	id := pdfCtx.ID
+	if len(id) != 2 {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		id = types.Array{types.HexLiteral(fmt.Sprintf("%x", b)), types.HexLiteral(fmt.Sprintf("%x", b))}
+	}
+	trailer := fmt.Sprintf("/Size %d /Root %d 0 R /ID %s", size, numbers[int(pdfCtx.Root.ObjectNumber)], id.PDFString())
+	if pdfCtx.Info != nil {
+		if infoNr, found := numbers[int(pdfCtx.Info.ObjectNumber)]; found {
+			trailer += fmt.Sprintf(" /Info %d 0 R", infoNr)
+		}
+	}
+
+	firstXRef := func(offsets map[int]int64, prev int64) []byte {
+		b := fmt.Appendf(nil, "xref\n%d %d\n", linNr, size-linNr)
+		for nr := linNr; nr < size; nr++ {
+			b = fmt.Appendf(b, "%010d 00000 n \n", offsets[nr])
+		}
+		return fmt.Appendf(b, "trailer\n<< %s /Prev %-10d >>\nstartxref\n0\n%%%%EOF\n", trailer, prev)
+	}
+
+	// Offsets in the hint tables are as if the hint stream was not there
+	linLength := int64(len(linDict(0, 0, 0, 0, 0)))
+	xrefLength := int64(len(firstXRef(nil, 0)))
+	hintOffset := int64(len(header)) + linLength + xrefLength + length(layout.docOpen)
+
+	offsets := map[int]int64{}
+	pos := hintOffset
+	place := func(objNrs []int) {
+		for _, objNr := range objNrs {
+			offsets[objNr] = pos
+			pos += int64(len(serialized[objNr]))
+		}
+	}
+	place(layout.firstPage)
+	for _, page := range layout.pages {
+		place(page)
+	}
+	place(layout.shared)
+	place(layout.other)
+
+	hint, err := linearizeHintStream(hintNr, layout, numbers, offsets, serialized)
+	if err != nil {
+		return err
+	}
+	hintLength := int64(len(hint))
+
+	// Actual offsets, by new object number
+	fileOffsets := map[int]int64{linNr: int64(len(header)), hintNr: hintOffset}
+	docOpenOffset := int64(len(header)) + linLength + xrefLength
+	for _, objNr := range layout.docOpen {
+		fileOffsets[numbers[objNr]] = docOpenOffset
+		docOpenOffset += int64(len(serialized[objNr]))
+	}
+	for objNr, offset := range offsets {
+		fileOffsets[numbers[objNr]] = offset + hintLength
+	}
+
+	e := hintOffset + hintLength + length(layout.firstPage)
+	mainXRefOffset := pos + hintLength
+
+	mainXRef := fmt.Appendf(nil, "xref\n0 %d", linNr)
+	t := mainXRefOffset + int64(len(mainXRef))
+	mainXRef = append(mainXRef, "\n0000000000 65535 f \n"...)
+	for nr := 1; nr < linNr; nr++ {
+		mainXRef = fmt.Appendf(mainXRef, "%010d 00000 n \n", fileOffsets[nr])
+	}
+	mainXRef = fmt.Appendf(mainXRef, "trailer\n<< /Size %d >>\nstartxref\n%d\n%%%%EOF\n", linNr, int64(len(header))+linLength)
+
+	l := mainXRefOffset + int64(len(mainXRef))
+
+	f, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	w.Write(header)
+	w.Write(linDict(l, hintOffset, hintLength, e, t))
+	w.Write(firstXRef(fileOffsets, mainXRefOffset))
+	write := func(objNrs []int) {
+		for _, objNr := range objNrs {
+			w.Write(serialized[objNr])
+		}
+	}
+	write(layout.docOpen)
+	w.Write(hint)
+	write(layout.firstPage)
+	for _, page := range layout.pages {
+		write(page)
+	}
+	write(layout.shared)
+	write(layout.other)
+	w.Write(mainXRef)
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// linearizeObjects sorts the objects reachable from the trailer into the sections of a linearized file.
+func linearizeObjects(pdfCtx *model.Context) (*linearizeLayout, error) {
+	rootNr := int(pdfCtx.Root.ObjectNumber)
+
+	// Inherited attributes have to be on each page, viewers do not load the page tree first
+	pageNrs := make([]int, pdfCtx.PageCount)
+	for pageNr := 1; pageNr <= pdfCtx.PageCount; pageNr++ {
+		pageDict, indRef, inhAttrs, err := pdfCtx.PageDict(pageNr, false)
+		if err != nil {
+			return nil, err
+		}
+		if indRef == nil {
+			return nil, fmt.Errorf("page %d is not an indirect object", pageNr)
+		}
+		pageNrs[pageNr-1] = int(indRef.ObjectNumber)
+
+		if inhAttrs == nil {
+			continue
+		}
+		if _, found := pageDict["Resources"]; !found && inhAttrs.Resources != nil {
+			pageDict["Resources"] = inhAttrs.Resources
+		}
+		if _, found := pageDict["MediaBox"]; !found && inhAttrs.MediaBox != nil {
+			pageDict["MediaBox"] = inhAttrs.MediaBox.Array()
+		}
+		if _, found := pageDict["CropBox"]; !found && inhAttrs.CropBox != nil {
+			pageDict["CropBox"] = inhAttrs.CropBox.Array()
+		}
+		if _, found := pageDict["Rotate"]; !found && inhAttrs.Rotate != 0 {
+			pageDict["Rotate"] = types.Integer(inhAttrs.Rotate)
+		}
+	}
+
+	// Page objects, page tree nodes and the catalog belong to no page
+	stop := map[int]bool{rootNr: true}
+	for _, objNr := range pageNrs {
+		stop[objNr] = true
+	}
+	nodes := []types.Object{pdfCtx.RootDict["Pages"]}
+	for len(nodes) > 0 {
+		indRef, ok := nodes[0].(types.IndirectRef)
+		nodes = nodes[1:]
+		if !ok || stop[int(indRef.ObjectNumber)] {
+			continue
+		}
+		stop[int(indRef.ObjectNumber)] = true
+		if d, err := pdfCtx.DereferenceDict(indRef); err == nil && d != nil {
+			nodes = append(nodes, d.ArrayEntry("Kids")...)
+		}
+	}
+
+	users := map[int][]int{}
+	pageObjs := make([][]int, len(pageNrs))
+	for i, objNr := range pageNrs {
+		objs := []int{objNr}
+		pageDict, err := pdfCtx.DereferenceDict(*types.NewIndirectRef(objNr, 0))
+		if err != nil {
+			return nil, err
+		}
+		seen := map[int]bool{objNr: true}
+		for _, o := range pageDict {
+			reachableObjects(pdfCtx, o, stop, seen, &objs)
+		}
+		for _, o := range objs {
+			users[o] = append(users[o], i)
+		}
+		pageObjs[i] = objs
+	}
+
+	docOpenSeen := map[int]bool{}
+	var docOpen []int
+	keys := []string{"ViewerPreferences", "OpenAction", "Threads"}
+	if pageMode := pdfCtx.RootDict.NameEntry("PageMode"); pageMode != nil && *pageMode == "UseOutlines" {
+		keys = append(keys, "Outlines")
+	}
+	for _, key := range keys {
+		reachableObjects(pdfCtx, pdfCtx.RootDict[key], stop, docOpenSeen, &docOpen)
+	}
+	// The fields of the form go with the pages showing their widgets (F.3.4), only the form
+	// dict and its resources open the document
+	if indRef, ok := pdfCtx.RootDict["AcroForm"].(types.IndirectRef); ok && !docOpenSeen[int(indRef.ObjectNumber)] {
+		docOpenSeen[int(indRef.ObjectNumber)] = true
+		docOpen = append(docOpen, int(indRef.ObjectNumber))
+	}
+	if form, err := pdfCtx.DereferenceDict(pdfCtx.RootDict["AcroForm"]); err == nil {
+		for key, o := range form {
+			if key != "Fields" {
+				reachableObjects(pdfCtx, o, stop, docOpenSeen, &docOpen)
+			}
+		}
+	}
+
+	all := []int{rootNr}
+	allSeen := map[int]bool{rootNr: true}
+	for _, o := range pdfCtx.RootDict {
+		reachableObjects(pdfCtx, o, nil, allSeen, &all)
+	}
+	if pdfCtx.Info != nil {
+		reachableObjects(pdfCtx, *pdfCtx.Info, nil, allSeen, &all)
+	}
+
+	layout := &linearizeLayout{
+		pages:      make([][]int, len(pageNrs)-1),
+		pageShared: make([][]int, len(pageNrs)),
+	}
+	placed := map[int]bool{}
+
+	layout.firstPage = pageObjs[0]
+	for _, objNr := range layout.firstPage {
+		placed[objNr] = true
+	}
+
+	layout.docOpen = []int{rootNr}
+	placed[rootNr] = true
+	for _, objNr := range docOpen {
+		if !placed[objNr] {
+			layout.docOpen = append(layout.docOpen, objNr)
+			placed[objNr] = true
+		}
+	}
+
+	for i, objs := range pageObjs[1:] {
+		for _, objNr := range objs {
+			if !placed[objNr] && len(users[objNr]) == 1 {
+				layout.pages[i] = append(layout.pages[i], objNr)
+				placed[objNr] = true
+			}
+		}
+	}
+
+	sharedSet := map[int]bool{}
+	for _, objs := range pageObjs[1:] {
+		for _, objNr := range objs {
+			if !placed[objNr] {
+				layout.shared = append(layout.shared, objNr)
+				placed[objNr] = true
+				sharedSet[objNr] = true
+			}
+		}
+	}
+
+	for _, objNr := range all {
+		if !placed[objNr] {
+			layout.other = append(layout.other, objNr)
+			placed[objNr] = true
+		}
+	}
+
+	firstPageSet := map[int]bool{}
+	for _, objNr := range layout.firstPage {
+		firstPageSet[objNr] = true
+	}
+	for i, objs := range pageObjs[1:] {
+		for _, objNr := range objs {
+			if firstPageSet[objNr] || sharedSet[objNr] {
+				layout.pageShared[i+1] = append(layout.pageShared[i+1], objNr)
+			}
+		}
+	}
+
+	return layout, nil
+}
+
+// reachableObjects appends the objects reachable from o to objs, in the order met, without
+// entering objects in stop or already seen.
+func reachableObjects(pdfCtx *model.Context, o types.Object, stop, seen map[int]bool, objs *[]int) {
+	switch o := o.(type) {
+	case types.IndirectRef:
+		objNr := int(o.ObjectNumber)
+		if seen[objNr] || stop[objNr] {
+			return
+		}
+		entry, found := pdfCtx.FindTableEntryLight(objNr)
+		if !found || entry.Free || entry.Object == nil {
+			return
+		}
+		seen[objNr] = true
+		*objs = append(*objs, objNr)
+		reachableObjects(pdfCtx, entry.Object, stop, seen, objs)
+	case types.Dict:
+		for _, v := range o {
+			reachableObjects(pdfCtx, v, stop, seen, objs)
+		}
+	case types.StreamDict:
+		reachableObjects(pdfCtx, o.Dict, stop, seen, objs)
+	case types.Array:
+		for _, v := range o {
+			reachableObjects(pdfCtx, v, stop, seen, objs)
+		}
+	}
+}
+
+// linearizedObject serializes object objNr as object newNr, with its references renumbered.
+func linearizedObject(pdfCtx *model.Context, objNr, newNr int, numbers map[int]int) ([]byte, error) {
+	entry, found := pdfCtx.FindTableEntryLight(objNr)
+	if !found || entry.Free {
+		return fmt.Appendf(nil, "%d 0 obj\nnull\nendobj\n", newNr), nil
+	}
+
+	sd, ok := entry.Object.(types.StreamDict)
+	if !ok {
+		s := "null"
+		if o := renumberObject(entry.Object, numbers); o != nil {
+			s = o.PDFString()
+		}
+		return fmt.Appendf(nil, "%d 0 obj\n%s\nendobj\n", newNr, s), nil
+	}
+
+	if sd.Raw == nil && sd.Content != nil {
+		if err := sd.Encode(); err != nil {
+			return nil, err
+		}
+	}
+
+	d := renumberObject(sd.Dict, numbers).(types.Dict)
+	d["Length"] = types.Integer(len(sd.Raw))
+
+	b := fmt.Appendf(nil, "%d 0 obj\n%s\nstream\n", newNr, d.PDFString())
+	b = append(b, sd.Raw...)
+	return append(b, "\nendstream\nendobj\n"...), nil
+}
+
+// renumberObject returns a copy of o with references renumbered, and references to objects
+// not written replaced by null.
+func renumberObject(o types.Object, numbers map[int]int) types.Object {
+	switch o := o.(type) {
+	case types.IndirectRef:
+		if nr, found := numbers[int(o.ObjectNumber)]; found {
+			return *types.NewIndirectRef(nr, 0)
+		}
+		return nil
+	case types.Dict:
+		d := types.NewDict()
+		for k, v := range o {
+			d[k] = renumberObject(v, numbers)
+		}
+		return d
+	case types.Array:
+		a := make(types.Array, len(o))
+		for i, v := range o {
+			a[i] = renumberObject(v, numbers)
+		}
+		return a
+	}
+	return o
+}
+
+// linearizeHintStream builds the primary hint stream object with the page offset and shared
+// object hint tables (PDF 32000-1:2008, F.4). Every shared object is a group of its own.
+// Content stream offsets and lengths are given as those of the whole page, as Acrobat does.
+// Objects of the document-open part are in neither table, even those pages use: they come
+// before the hint stream and the first page and are read with the catalog when the document
+// is opened, so no page has to ask for them.
+func linearizeHintStream(hintNr int, layout *linearizeLayout, numbers map[int]int, offsets map[int]int64, serialized map[int][]byte) ([]byte, error) {
+	pages := append([][]int{layout.firstPage}, layout.pages...)
+
+	sharedIDs := map[int]int{}
+	for _, objNr := range layout.firstPage {
+		sharedIDs[objNr] = len(sharedIDs)
+	}
+	for _, objNr := range layout.shared {
+		sharedIDs[objNr] = len(sharedIDs)
+	}
+
+	pageLengths := make([]int64, len(pages))
+	for i, objs := range pages {
+		for _, objNr := range objs {
+			pageLengths[i] += int64(len(serialized[objNr]))
+		}
+	}
+
+	minObjs, maxObjs := len(pages[0]), len(pages[0])
+	minLength, maxLength := pageLengths[0], pageLengths[0]
+	maxShared, maxSharedID := 0, 0
+	for i, objs := range pages {
+		minObjs, maxObjs = min(minObjs, len(objs)), max(maxObjs, len(objs))
+		minLength, maxLength = min(minLength, pageLengths[i]), max(maxLength, pageLengths[i])
+		maxShared = max(maxShared, len(layout.pageShared[i]))
+		for _, objNr := range layout.pageShared[i] {
+			maxSharedID = max(maxSharedID, sharedIDs[objNr])
+		}
+	}
+
+	objsBits := bitsFor(uint64(maxObjs - minObjs))
+	lengthBits := bitsFor(uint64(maxLength - minLength))
+	sharedBits := bitsFor(uint64(maxShared))
+	sharedIDBits := bitsFor(uint64(maxSharedID))
+
+	var w hintWriter
+
+	// Page offset hint table header
+	w.write(uint64(minObjs), 32)
+	w.write(uint64(offsets[layout.firstPage[0]]), 32)
+	w.write(uint64(objsBits), 16)
+	w.write(uint64(minLength), 32)
+	w.write(uint64(lengthBits), 16)
+	w.write(0, 32)
+	w.write(0, 16)
+	w.write(uint64(minLength), 32)
+	w.write(uint64(lengthBits), 16)
+	w.write(uint64(sharedBits), 16)
+	w.write(uint64(sharedIDBits), 16)
+	w.write(0, 16)
+	w.write(1, 16)
+
+	// Page offset hint table entries, item by item for all pages
+	for _, objs := range pages {
+		w.write(uint64(len(objs)-minObjs), objsBits)
+	}
+	w.align()
+	for i := range pages {
+		w.write(uint64(pageLengths[i]-minLength), lengthBits)
+	}
+	w.align()
+	for i := range pages {
+		w.write(uint64(len(layout.pageShared[i])), sharedBits)
+	}
+	w.align()
+	for i := range pages {
+		for _, objNr := range layout.pageShared[i] {
+			w.write(uint64(sharedIDs[objNr]), sharedIDBits)
+		}
+	}
+	w.align()
+	// Numerators of the fractional positions take no bits, content offsets neither
+	for i := range pages {
+		w.write(uint64(pageLengths[i]-minLength), lengthBits)
+	}
+	w.align()
+
+	sharedOffset := len(w.b)
+
+	groups := append(append([]int{}, layout.firstPage...), layout.shared...)
+	minGroup, maxGroup := int64(len(serialized[groups[0]])), int64(len(serialized[groups[0]]))
+	for _, objNr := range groups {
+		n := int64(len(serialized[objNr]))
+		minGroup, maxGroup = min(minGroup, n), max(maxGroup, n)
+	}
+	groupBits := bitsFor(uint64(maxGroup - minGroup))
+
+	// Shared object hint table header
+	var firstShared, firstSharedOffset int64
+	if len(layout.shared) > 0 {
+		firstShared = int64(numbers[layout.shared[0]])
+		firstSharedOffset = offsets[layout.shared[0]]
+	}
+	w.write(uint64(firstShared), 32)
+	w.write(uint64(firstSharedOffset), 32)
+	w.write(uint64(len(layout.firstPage)), 32)
+	w.write(uint64(len(groups)), 32)
+	w.write(0, 16)
+	w.write(uint64(minGroup), 32)
+	w.write(uint64(groupBits), 16)
+
+	// Shared object hint table entries: lengths, then no MD5 signatures, object counts take no bits
+	for _, objNr := range groups {
+		w.write(uint64(int64(len(serialized[objNr]))-minGroup), groupBits)
+	}
+	w.align()
+	for range groups {
+		w.write(0, 1)
+	}
+	w.align()
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(w.b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	b := fmt.Appendf(nil, "%d 0 obj\n<</Filter/FlateDecode/Length %d/S %d>>\nstream\n", hintNr, compressed.Len(), sharedOffset)
+	b = append(b, compressed.Bytes()...)
+	return append(b, "\nendstream\nendobj\n"...), nil
+}
+
+// hintWriter packs the bit fields of hint tables.
+type hintWriter struct {
+	b    []byte
+	bits int // bits used of the last byte, 0 if it is full
+}
+
+func (w *hintWriter) write(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.bits == 0 {
+			w.b = append(w.b, 0)
+		}
+		if v>>uint(i)&1 == 1 {
+			w.b[len(w.b)-1] |= 0x80 >> uint(w.bits)
+		}
+		w.bits = (w.bits + 1) % 8
+	}
+}
+
+// align moves on to the next byte boundary.
+func (w *hintWriter) align() {
+	w.bits = 0
+}
+
+// bitsFor returns the number of bits needed to represent v.
+func bitsFor(v uint64) int {
+	return bits.Len64(v)
+}
@@ -0,0 +1,497 @@
+package routes
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"pdftool/internal/pdftest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// testLinearizeInput writes a document of four pages with a font all pages use, an image
+// pages 2 and 3 share, form fields on pages 2 and 3 and outlines shown on opening.
+func testLinearizeInput(t *testing.T) string {
+	var b pdftest.Builder
+	font := b.Add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	image := b.Add(pdftest.Stream(grayPixel, "A"))
+	field, group := b.Reserve(), b.Reserve()
+	groupWidgets := []int{b.Reserve(), b.Reserve()}
+	outlines, outline := b.Reserve(), b.Reserve()
+
+	pages := make([]string, 4)
+	for i := range pages {
+		content := fmt.Sprintf("BT /F1 24 Tf 40 90 Td (Page %d) Tj ET", i+1)
+		res := fmt.Sprintf("/Font << /F1 %d 0 R >>", font)
+		if i == 1 || i == 2 {
+			content += " q 50 0 0 50 100 100 cm /Im1 Do Q"
+			res += fmt.Sprintf(" /XObject << /Im1 %d 0 R >>", image)
+		}
+		pages[i] = fmt.Sprintf("/MediaBox [0 0 200 200] /Resources << %s >> /Contents %d 0 R", res, b.Add(pdftest.Stream("", content)))
+	}
+	pages[1] += fmt.Sprintf(" /Annots [%d 0 R %d 0 R]", field, groupWidgets[0])
+	pages[2] += fmt.Sprintf(" /Annots [%d 0 R]", groupWidgets[1])
+	root := b.Document(pages...)
+	tree := root - len(pages) - 1
+
+	widget := "<< /Type /Annot /Subtype /Widget /Rect [10 10 190 30] /P %d 0 R %s >>"
+	b.Set(field, fmt.Sprintf(widget, tree+2, "/FT /Tx /T (name) /V (value)"))
+	b.Set(group, fmt.Sprintf("<< /FT /Tx /T (group) /Kids [%d 0 R %d 0 R] >>", groupWidgets[0], groupWidgets[1]))
+	for i, nr := range groupWidgets {
+		b.Set(nr, fmt.Sprintf(widget, tree+2+i, fmt.Sprintf("/Parent %d 0 R", group)))
+	}
+	b.Set(outlines, fmt.Sprintf("<< /Type /Outlines /First %d 0 R /Last %d 0 R /Count 1 >>", outline, outline))
+	b.Set(outline, fmt.Sprintf("<< /Title (Page 2) /Parent %d 0 R /Dest [%d 0 R /Fit] >>", outlines, tree+2))
+	b.Set(root, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R /PageMode /UseOutlines /Outlines %d 0 R "+
+		"/AcroForm << /Fields [%d 0 R %d 0 R] /DA (/Helv 0 Tf 0 g) /DR << /Font << /Helv %d 0 R >> >> >> >>",
+		tree, outlines, field, group, font))
+
+	return b.Write(t, root)
+}
+
+func TestWriteLinearizedFile(t *testing.T) {
+	pdfCtx, err := api.ReadContextFile(testLinearizeInput(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outFile := filepath.Join(t.TempDir(), "linearized.pdf")
+	if err := writeLinearizedFile(pdfCtx, outFile); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := api.ReadContextFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lf := parseTestLinearized(t, file)
+
+	pageNrs := make([]int, out.PageCount)
+	for pageNr := 1; pageNr <= out.PageCount; pageNr++ {
+		_, indRef, _, err := out.PageDict(pageNr, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pageNrs[pageNr-1] = int(indRef.ObjectNumber)
+	}
+
+	// Linearization dict
+	if got := lf.dict["L"]; got != int64(len(file)) {
+		t.Errorf("got /L %d, want the file length %d", got, len(file))
+	}
+	if got := lf.dict["N"]; got != int64(out.PageCount) {
+		t.Errorf("got /N %d, want %d pages", got, out.PageCount)
+	}
+	if got := lf.dict["O"]; got != int64(pageNrs[0]) {
+		t.Errorf("got /O %d, want the first page object %d", got, pageNrs[0])
+	}
+	hintOffset, hintLength := lf.dict["H0"], lf.dict["H1"]
+	if nr, found := lf.objectAt[hintOffset]; !found {
+		t.Errorf("no object at the /H offset %d", hintOffset)
+	} else if !bytes.Contains(file[hintOffset:hintOffset+hintLength], []byte("/S ")) {
+		t.Errorf("object %d at the /H offset is not the hint stream", nr)
+	}
+	firstPageStart := hintOffset + hintLength
+	if got := lf.objectAt[firstPageStart]; got != pageNrs[0] {
+		t.Errorf("got object %d after the hint stream, want the first page %d", got, pageNrs[0])
+	}
+	e := lf.dict["E"]
+	if _, found := lf.objectAt[e]; !found && e != lf.mainXRef {
+		t.Errorf("/E %d is not where an object or the main xref starts", e)
+	}
+	if got := lf.dict["T"]; got != lf.mainXRef+int64(len(fmt.Sprintf("xref\n0 %d", lf.firstXRefStart))) ||
+		!bytes.HasPrefix(file[got:], []byte("\n0000000000 65535 f \n")) {
+		t.Errorf("/T %d is not before the first entry of the main xref at %d", got, lf.mainXRef)
+	}
+
+	// Cross-reference tables
+	for nr, offset := range lf.xref {
+		if lf.offsets[nr] != offset {
+			t.Errorf("xref has object %d at %d, it is at %d", nr, offset, lf.offsets[nr])
+		}
+	}
+	if len(lf.xref) != len(lf.offsets) {
+		t.Errorf("xref tables have %d objects, the file %d", len(lf.xref), len(lf.offsets))
+	}
+
+	// Sections: part 4 before the hint stream, the first page up to /E, then the other pages
+	// and the objects they share
+	section := func(from, to int64) []int {
+		var nrs []int
+		for _, offset := range lf.order {
+			if offset >= from && offset < to {
+				nrs = append(nrs, lf.objectAt[offset])
+			}
+		}
+		return nrs
+	}
+	firstPage := section(firstPageStart, e)
+	pageObjs := testPageObjects(t, out, pageNrs)
+	for nr := range pageObjs[0] {
+		if !slices.Contains(firstPage, nr) && lf.offsets[nr] >= hintOffset {
+			t.Errorf("object %d of the first page is not in its section", nr)
+		}
+	}
+	docOpen := section(0, hintOffset)
+	for _, nr := range testFieldObjects(t, out) {
+		if slices.Contains(docOpen, nr) {
+			t.Errorf("form field object %d is in the document-open part", nr)
+		}
+	}
+
+	// Page offset hint table
+	adjust := func(offset int64) int64 {
+		if offset >= hintOffset {
+			return offset + hintLength
+		}
+		return offset
+	}
+	h := lf.hint
+	minObjs, firstPageOffset, objsBits := h.read(32), h.read(32), h.read(16)
+	minLength, lengthBits := h.read(32), h.read(16)
+	h.read(32) // least content stream offset
+	h.read(16)
+	h.read(32) // least content stream length
+	contentLengthBits := h.read(16)
+	sharedBits, sharedIDBits, numeratorBits := h.read(16), h.read(16), h.read(16)
+	h.read(16) // denominator
+
+	if got := adjust(int64(firstPageOffset)); got != firstPageStart {
+		t.Errorf("page offset hint table has the first page at %d, it is at %d", got, firstPageStart)
+	}
+
+	n := out.PageCount
+	objCounts, lengths, sharedCounts := make([]int, n), make([]int64, n), make([]int, n)
+	for i := range n {
+		objCounts[i] = int(minObjs + h.read(objsBits))
+	}
+	h.align()
+	for i := range n {
+		lengths[i] = int64(minLength + h.read(lengthBits))
+	}
+	h.align()
+	for i := range n {
+		sharedCounts[i] = int(h.read(sharedBits))
+	}
+	h.align()
+	sharedIDs := make([][]int, n)
+	for i := range n {
+		for range sharedCounts[i] {
+			sharedIDs[i] = append(sharedIDs[i], int(h.read(sharedIDBits)))
+		}
+	}
+	h.align()
+	for i := range n {
+		for range sharedCounts[i] {
+			h.read(numeratorBits)
+		}
+	}
+	h.align()
+	for range n {
+		h.read(contentLengthBits)
+	}
+	h.align()
+
+	pageSections := make([][]int, n)
+	pageStart := firstPageStart
+	for i := range n {
+		objs := section(pageStart, pageStart+lengths[i])
+		pageSections[i] = objs
+		if len(objs) == 0 || objs[0] != pageNrs[i] {
+			t.Errorf("page %d section at %d does not start with its page object %d: %v", i+1, pageStart, pageNrs[i], objs)
+		}
+		if len(objs) != objCounts[i] {
+			t.Errorf("page %d section has %d objects, the hint table says %d", i+1, len(objs), objCounts[i])
+		}
+		for _, nr := range objs {
+			if !pageObjs[i][nr] {
+				t.Errorf("object %d in the section of page %d is not used by it", nr, i+1)
+			}
+		}
+		if i == 0 && pageStart+lengths[i] != e {
+			t.Errorf("first page section ends at %d, /E is %d", pageStart+lengths[i], e)
+		}
+		pageStart += lengths[i]
+	}
+
+	// Shared object hint table
+	h.seek(lf.sharedTableOffset)
+	firstShared, firstSharedOffset := int(h.read(32)), int64(h.read(32))
+	firstPageGroups, groups := int(h.read(32)), int(h.read(32))
+	h.read(16) // bits of object counts
+	minGroup, groupBits := h.read(32), h.read(16)
+
+	if firstPageGroups != len(firstPage) {
+		t.Errorf("shared object hint table has %d first page objects, the section %d", firstPageGroups, len(firstPage))
+	}
+	groupObjs := make([]int, groups)
+	pos := firstPageStart
+	for g := range groups {
+		if g == firstPageGroups {
+			pos = adjust(firstSharedOffset)
+			if lf.objectAt[pos] != firstShared {
+				t.Errorf("first shared object %d is not at %d", firstShared, pos)
+			}
+		}
+		nr, found := lf.objectAt[pos]
+		if !found {
+			t.Fatalf("shared object group %d at %d is not an object", g, pos)
+		}
+		groupObjs[g] = nr
+		length := int64(minGroup + h.read(groupBits))
+		if length != lf.lengths[nr] {
+			t.Errorf("shared object group %d has length %d, object %d is %d bytes", g, length, nr, lf.lengths[nr])
+		}
+		pos += length
+	}
+	for i, ids := range sharedIDs {
+		for _, id := range ids {
+			if id >= groups || !pageObjs[i][groupObjs[id]] {
+				t.Errorf("page %d refers to shared object group %d it does not use", i+1, id)
+			}
+		}
+	}
+	// Every other page finds what it uses in its section, in a shared group it lists or
+	// in the document-open part read before any page
+	for i := 1; i < n; i++ {
+		for nr := range pageObjs[i] {
+			listed := slices.ContainsFunc(sharedIDs[i], func(id int) bool { return id < groups && groupObjs[id] == nr })
+			if !listed && !slices.Contains(pageSections[i], nr) && !slices.Contains(docOpen, nr) {
+				t.Errorf("page %d uses object %d, neither in its section nor a shared group it lists", i+1, nr)
+			}
+		}
+	}
+}
+
+func TestWriteLinearizedFileQPDF(t *testing.T) {
+	qpdf, err := exec.LookPath("qpdf")
+	if err != nil {
+		// CI installs qpdf, there the check must not be skipped
+		if os.Getenv("CI") != "" {
+			t.Fatal("qpdf is not installed")
+		}
+		t.Skip("qpdf is not installed")
+	}
+
+	pdfCtx, err := api.ReadContextFile(testLinearizeInput(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outFile := filepath.Join(t.TempDir(), "linearized.pdf")
+	if err := writeLinearizedFile(pdfCtx, outFile); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(qpdf, "--check-linearization", outFile).CombinedOutput()
+	if err != nil || !strings.Contains(string(out), "no linearization errors") {
+		t.Errorf("qpdf --check-linearization: %v\n%s", err, out)
+	}
+}
+
+// testLinearized is a linearized file taken apart.
+type testLinearized struct {
+	dict map[string]int64
+	// object offsets by number, and object numbers and lengths by offset
+	offsets  map[int]int64
+	objectAt map[int64]int
+	lengths  map[int]int64
+	order    []int64
+	// xref offsets of both tables by object number
+	xref              map[int]int64
+	firstXRefStart    int
+	mainXRef          int64
+	hint              *testBitReader
+	sharedTableOffset int
+}
+
+var (
+	testObjectPattern = regexp.MustCompile(`(?m)^(\d+) 0 obj\n`)
+	testLinPattern    = regexp.MustCompile(`/(L|O|E|N|T) (\d+)`)
+	testHintPattern   = regexp.MustCompile(`/H \[ (\d+) +(\d+) +\]`)
+	testXRefPattern   = regexp.MustCompile(`xref\n(\d+) (\d+)\n`)
+)
+
+// parseTestLinearized finds the objects, xref tables and hint stream of file, written
+// the way writeLinearizedFile writes them.
+func parseTestLinearized(t *testing.T, file []byte) *testLinearized {
+	t.Helper()
+	lf := &testLinearized{
+		dict:     map[string]int64{},
+		offsets:  map[int]int64{},
+		objectAt: map[int64]int{},
+		lengths:  map[int]int64{},
+		xref:     map[int]int64{},
+	}
+
+	for _, m := range testObjectPattern.FindAllSubmatchIndex(file, -1) {
+		nr, _ := strconv.Atoi(string(file[m[2]:m[3]]))
+		offset := int64(m[0])
+		end := bytes.Index(file[offset:], []byte("endobj\n"))
+		lf.offsets[nr], lf.objectAt[offset], lf.lengths[nr] = offset, nr, int64(end+len("endobj\n"))
+		lf.order = append(lf.order, offset)
+	}
+
+	linDict := file[:lf.lengths[lf.objectAt[lf.order[0]]]+lf.order[0]]
+	for _, m := range testLinPattern.FindAllSubmatch(linDict, -1) {
+		lf.dict[string(m[1])], _ = strconv.ParseInt(string(m[2]), 10, 64)
+	}
+	if m := testHintPattern.FindSubmatch(linDict); m != nil {
+		lf.dict["H0"], _ = strconv.ParseInt(string(m[1]), 10, 64)
+		lf.dict["H1"], _ = strconv.ParseInt(string(m[2]), 10, 64)
+	}
+
+	// The first page xref follows the linearization dict, its trailer's /Prev is the main xref
+	for i, m := range testXRefPattern.FindAllSubmatchIndex(file, -1) {
+		start, _ := strconv.Atoi(string(file[m[2]:m[3]]))
+		count, _ := strconv.Atoi(string(file[m[4]:m[5]]))
+		entries := file[m[1]:]
+		for j := range count {
+			entry := string(entries[j*20 : j*20+20])
+			if strings.HasSuffix(entry, "n \n") {
+				lf.xref[start+j], _ = strconv.ParseInt(entry[:10], 10, 64)
+			}
+		}
+		if i == 0 {
+			lf.firstXRefStart = start
+			trailer := string(entries[count*20:])
+			prev := regexp.MustCompile(`/Prev (\d+)`).FindStringSubmatch(trailer)
+			if prev == nil {
+				t.Fatal("first page trailer has no /Prev")
+			}
+			lf.mainXRef, _ = strconv.ParseInt(prev[1], 10, 64)
+		} else if int64(m[0]) != lf.mainXRef {
+			t.Errorf("main xref is at %d, /Prev says %d", m[0], lf.mainXRef)
+		}
+	}
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(file)
+	if startxref == nil || string(startxref[1]) != strconv.Itoa(int(lf.order[0]+lf.lengths[lf.objectAt[lf.order[0]]])) {
+		t.Errorf("last startxref does not point at the first page xref")
+	}
+
+	hintObj := file[lf.dict["H0"] : lf.dict["H0"]+lf.dict["H1"]]
+	s := regexp.MustCompile(`/S (\d+)`).FindSubmatch(hintObj)
+	start := bytes.Index(hintObj, []byte("stream\n")) + len("stream\n")
+	end := bytes.LastIndex(hintObj, []byte("\nendstream"))
+	if s == nil || start < len("stream\n") || end < start {
+		t.Fatal("hint stream not found")
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(hintObj[start:end]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hint, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lf.hint = &testBitReader{b: hint}
+	lf.sharedTableOffset, _ = strconv.Atoi(string(s[1]))
+
+	return lf
+}
+
+// testPageObjects returns per page the objects reachable from it, not entering other pages,
+// the page tree or the catalog.
+func testPageObjects(t *testing.T, pdfCtx *model.Context, pageNrs []int) []map[int]bool {
+	t.Helper()
+	stop := map[int]bool{int(pdfCtx.Root.ObjectNumber): true}
+	if indRef, ok := pdfCtx.RootDict["Pages"].(types.IndirectRef); ok {
+		stop[int(indRef.ObjectNumber)] = true
+	}
+	for _, nr := range pageNrs {
+		stop[nr] = true
+	}
+
+	objs := make([]map[int]bool, len(pageNrs))
+	for i, nr := range pageNrs {
+		seen := map[int]bool{nr: true}
+		var visit func(o types.Object)
+		visit = func(o types.Object) {
+			switch o := o.(type) {
+			case types.IndirectRef:
+				if seen[int(o.ObjectNumber)] || stop[int(o.ObjectNumber)] {
+					return
+				}
+				seen[int(o.ObjectNumber)] = true
+				obj, err := pdfCtx.Dereference(o)
+				if err != nil {
+					t.Fatal(err)
+				}
+				visit(obj)
+			case types.Dict:
+				for _, v := range o {
+					visit(v)
+				}
+			case types.StreamDict:
+				visit(o.Dict)
+			case types.Array:
+				for _, v := range o {
+					visit(v)
+				}
+			}
+		}
+		pageDict, err := pdfCtx.DereferenceDict(*types.NewIndirectRef(nr, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		visit(pageDict)
+		objs[i] = seen
+	}
+	return objs
+}
+
+// testFieldObjects returns the object numbers of the fields of the form of pdfCtx.
+func testFieldObjects(t *testing.T, pdfCtx *model.Context) []int {
+	t.Helper()
+	form, err := pdfCtx.DereferenceDict(pdfCtx.RootDict["AcroForm"])
+	if err != nil || form == nil {
+		t.Fatalf("form not found: %v", err)
+	}
+	var nrs []int
+	for _, o := range form.ArrayEntry("Fields") {
+		if indRef, ok := o.(types.IndirectRef); ok {
+			nrs = append(nrs, int(indRef.ObjectNumber))
+		}
+	}
+	return nrs
+}
+
+// testBitReader reads the bit fields of hint tables.
+type testBitReader struct {
+	b   []byte
+	pos int // in bits
+}
+
+func (r *testBitReader) read(n uint64) uint64 {
+	var v uint64
+	for range n {
+		v <<= 1
+		if r.pos/8 < len(r.b) && r.b[r.pos/8]&(0x80>>uint(r.pos%8)) != 0 {
+			v |= 1
+		}
+		r.pos++
+	}
+	return v
+}
+
+func (r *testBitReader) align() {
+	r.pos = (r.pos + 7) / 8 * 8
+}
+
+func (r *testBitReader) seek(offset int) {
+	r.pos = offset * 8
+}
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"pdftool/server/helper"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/rs/zerolog/log"
+)
+
+// @Summary Merge PDF files
+// @Description Combines multiple PDF files into one, in the order given
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce octet-stream
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF files to merge (repeat the field for each file)"
+// @Param request body object false "JSON request with base64_pdfs array"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/merge [post]
+func Merge(ctx fiber.Ctx) error {
+	result, err := helper.ProcessMultiPDFRequest(ctx, helper.PDFProcessOptions{
+		OutputPrefix: "merged",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	if len(result.InputPaths) < 2 {
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			"At least two PDF files are required",
+		)
+	}
+
+	if err := api.ValidateFiles(result.InputPaths, nil); err != nil {
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			"File is invalid or corrupted. Please upload a valid PDF.",
+		)
+	}
+
+	if err := api.MergeCreateFile(result.InputPaths, result.OutputPath, false, nil); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusInternalServerError,
+			helper.TransformPDFCPUErrorToResponse(err),
+		)
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
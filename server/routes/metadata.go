@@ -0,0 +1,196 @@
+package routes
+
+import (
+	"fmt"
+	"os"
+	"pdftool/server/helper"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v3"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/rs/zerolog/log"
+)
+
+type metadataOptions struct {
+	Title      *string           `json:"title" form:"title"`
+	Author     *string           `json:"author" form:"author"`
+	Subject    *string           `json:"subject" form:"subject"`
+	Keywords   *string           `json:"keywords" form:"keywords"`
+	Creator    *string           `json:"creator" form:"creator"`
+	ClearAll   bool              `json:"clear_all" form:"clear_all"`
+	Properties map[string]string `json:"properties" form:"-"`
+}
+
+// @Summary Set or clear document properties
+// @Description Sets the title, author, subject, keywords and creator of the document info dictionary,
+// @Description plus any custom properties. An empty value clears the property, clear_all removes all of them first.
+// @Description The producer and dates are always updated on write.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce octet-stream
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file to update"
+// @Param request body object false "JSON request with base64 PDF"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Param title formData string false "Document title"
+// @Param author formData string false "Document author"
+// @Param subject formData string false "Document subject"
+// @Param keywords formData string false "Document keywords"
+// @Param creator formData string false "Application that created the original document"
+// @Param properties formData string false "JSON object of custom property name to value"
+// @Param clear_all formData bool false "Remove all existing properties before setting new ones"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/metadata [post]
+func Metadata(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "metadata",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	var opts metadataOptions
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid metadata options")
+	}
+
+	properties, propErr := metadataProperties(ctx)
+	if propErr != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(propErr))
+	}
+	opts.Properties = properties
+
+	if stepErr := metadataPDF(result.InputPath, result.OutputPath, result.Password, opts); stepErr != nil {
+		return helper.SendErrorResponse(ctx, stepErr.code, stepErr.message)
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// metadataProperties reads the optional custom properties object, sent as JSON in the body
+// or as a JSON string field in a multipart form.
+func metadataProperties(ctx fiber.Ctx) (map[string]string, error) {
+	properties := map[string]string{}
+	if strings.HasPrefix(ctx.Get("Content-Type"), "multipart/form-data") {
+		if value := ctx.FormValue("properties"); value != "" {
+			if err := json.Unmarshal([]byte(value), &properties); err != nil {
+				return nil, fmt.Errorf("invalid properties: %v", err)
+			}
+		}
+	} else {
+		var request struct {
+			Properties map[string]string `json:"properties"`
+		}
+		if err := ctx.Bind().Body(&request); err != nil {
+			return nil, fmt.Errorf("invalid properties: %v", err)
+		}
+		for key, value := range request.Properties {
+			properties[key] = value
+		}
+	}
+
+	return properties, nil
+}
+
+// metadataPDF sets the document properties of inFile, opened with password, as opts
+// tell and writes outFile.
+func metadataPDF(inFile, outFile, password string, opts metadataOptions) *stepError {
+	properties := map[string]string{}
+	for key, value := range opts.Properties {
+		if key == "" || strings.ContainsAny(key, " /()<>[]{}%#") {
+			return newStepError(fiber.StatusBadRequest, fmt.Sprintf("Invalid property name %q", key))
+		}
+		properties[key] = value
+	}
+
+	for key, value := range map[string]*string{
+		"Title":    opts.Title,
+		"Author":   opts.Author,
+		"Subject":  opts.Subject,
+		"Keywords": opts.Keywords,
+		"Creator":  opts.Creator,
+	} {
+		if value != nil {
+			properties[key] = *value
+		}
+	}
+
+	if len(properties) == 0 && !opts.ClearAll {
+		return newStepError(fiber.StatusBadRequest, "At least one property or clear_all is required")
+	}
+
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW = password
+	conf.OwnerPW = password
+
+	if err := api.ValidateFile(inFile, conf); err != nil {
+		return newStepError(fiber.StatusBadRequest, "File is invalid, corrupted or the password is wrong.")
+	}
+
+	if err := setMetadataFile(inFile, outFile, properties, opts.ClearAll, conf); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return newStepError(fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(err))
+	}
+
+	return nil
+}
+
+// setMetadataFile writes properties to the document info dict of inFile. Empty values
+// remove the entry, clearAll drops every existing entry first.
+func setMetadataFile(inFile, outFile string, properties map[string]string, clearAll bool, conf *model.Configuration) error {
+	f, err := os.Open(inFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	conf.Cmd = model.ADDPROPERTIES
+
+	pdfCtx, err := api.ReadValidateAndOptimize(f, conf)
+	if err != nil {
+		return err
+	}
+
+	var infoDict types.Dict
+	if pdfCtx.Info != nil {
+		if infoDict, err = pdfCtx.DereferenceDict(*pdfCtx.Info); err != nil {
+			return err
+		}
+	}
+
+	if clearAll && infoDict != nil {
+		for key := range infoDict {
+			delete(infoDict, key)
+		}
+		pdfCtx.Properties = map[string]string{}
+	}
+
+	set := map[string]string{}
+	for key, value := range properties {
+		if value != "" {
+			set[key] = value
+			continue
+		}
+		if infoDict != nil {
+			delete(infoDict, key)
+		}
+		delete(pdfCtx.Properties, key)
+	}
+
+	if len(set) > 0 {
+		if err := pdfcpu.PropertiesAdd(pdfCtx, set); err != nil {
+			return err
+		}
+	}
+
+	return api.WriteContextFile(pdfCtx, outFile)
+}
@@ -1,164 +1,350 @@
 package routes
 
 import (
-	"bytes"
-	"fmt"
-	"io"
-	"net/http"
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
 	"pdftool/server/helper"
 	"pdftool/types"
+	"strconv"
+	"strings"
+	"unicode"
 
-	"github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v3"
-	"github.com/gosimple/slug"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 	"github.com/rs/zerolog/log"
 )
 
+// Pages whose text layer has fewer visible characters than this are sent to OCR.
+const minPageTextChars = 20
+
+// Sources of the text of an OCR page.
+const (
+	ocrSourceText = "text"
+	ocrSourceOCR  = "ocr"
+)
+
+// ocrOutput is the /v1/ocr response, in the shape of the Mistral OCR API response.
+type ocrOutput struct {
+	Pages     []ocrPage `json:"pages"`
+	UsageInfo struct {
+		PagesProcessed int `json:"pages_processed"`
+		DocSizeBytes   int `json:"doc_size_bytes"`
+	} `json:"usage_info"`
+}
+
+// ocrPage is the text of a page. Index counts from 0 and Source tells whether the text
+// comes from OCR or from the page's text layer.
+type ocrPage struct {
+	Index      int    `json:"index"`
+	Markdown   string `json:"markdown"`
+	Images     []any  `json:"images"`
+	Dimensions struct {
+		Dpi    int `json:"dpi"`
+		Height int `json:"height"`
+		Width  int `json:"width"`
+	} `json:"dimensions"`
+	Source string `json:"source"`
+	// words are the recognized words, for providers that locate them
+	words []ocrWord
+}
+
+// ocrWord is a recognized word and its box in pixels of the page image, from the top left.
+type ocrWord struct {
+	text                     string
+	left, top, width, height float64
+}
+
 // @Summary Perform OCR on a PDF file
-// @Description Uploads a PDF file and performs OCR using Mistral OCR API
+// @Description Performs OCR on a PDF file with the configured provider, the Mistral OCR API or a local Tesseract.
+// @Description Documents are shared with Mistral through S3, or sent inline when S3 is disabled or OCR_INLINE is set.
+// @Description Pages that already have a text layer are not sent to OCR, their extracted text is returned instead,
+// @Description unless force_ocr selects them. Pages whose text can not be read are sent to OCR as well.
+// @Description With output=pdf, the PDF itself is returned with the recognized words laid over the OCR pages
+// @Description as invisible text, which makes scanned pages searchable.
 // @Tags PDF Operations
-// @Accept multipart/form-data
-// @Produce json
+// @Accept multipart/form-data,application/json
+// @Produce json,application/pdf
 // @Security ApiKeyAuth
-// @Param file formData file true "PDF file to process"
+// @Param file formData file false "PDF file to process"
+// @Param request body object false "JSON request with base64 PDF"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Param output formData string false "Response format, json (default) or pdf for the PDF with an invisible text layer over the OCR pages, which needs tesseract"
+// @Param force_ocr formData string false "Pages sent to OCR even if they have a text layer, e.g. 1,3-5 or 1- for all"
+// @Param callback_url formData string false "URL the signed result is posted to once done, which runs the request as a job and responds with it"
 // @Success 200 {object} types.Response
+// @Success 202 {object} types.Response
 // @Failure 400 {object} types.Response
 // @Failure 401 {object} types.Response
 // @Failure 408 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Failure 503 {object} types.Response
 // @Router /v1/ocr [post]
 func OCR(ctx fiber.Ctx) error {
 	done := make(chan struct{})
 	defer close(done)
 
-	file, err := ctx.FormFile("file")
-	if err != nil {
-		log.Error().Err(err).Caller().Send()
+	// With a callback, the request is run as a job and its result posted once done
+	var callback struct {
+		CallbackURL string `json:"callback_url" form:"callback_url"`
+	}
+	if err := ctx.Bind().Body(&callback); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid OCR options")
+	}
+	if callback.CallbackURL != "" && !runningAsJob(ctx) {
+		return enqueueJob(ctx, "ocr", callback.CallbackURL)
+	}
+
+	result, pdfErr := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "ocr",
+		OutputExt:       ".pdf",
+	})
+	if pdfErr != nil {
+		return helper.SendErrorResponse(ctx, pdfErr.Code, pdfErr.Message)
+	}
+	defer result.Cleanup()
+
+	var opts struct {
+		Output   string `json:"output" form:"output"`
+		ForceOCR string `json:"force_ocr" form:"force_ocr"`
+	}
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid OCR options")
+	}
+
+	searchable := false
+	switch strings.ToLower(opts.Output) {
+	case "", "json":
+	case "pdf":
+		searchable = true
+	default:
 		return helper.SendErrorResponse(
 			ctx,
 			fiber.StatusBadRequest,
-			"No file uploaded",
+			"Invalid output, use json or pdf",
 		)
 	}
 
-	if file.Header.Get("Content-Type") != "application/pdf" {
+	forcedPages, err := api.ParsePageSelection(opts.ForceOCR)
+	if err != nil {
 		return helper.SendErrorResponse(
 			ctx,
 			fiber.StatusBadRequest,
-			"Invalid file type. Only PDF files are allowed",
+			"Invalid force_ocr page selection",
 		)
 	}
 
-	uploadedFile := slug.MakeLang(file.Filename, "en")
-	select {
-	case <-ctx.Context().Done():
-		return helper.SendErrorResponse(
-			ctx,
-			fiber.StatusRequestTimeout,
-			"Upload cancelled",
-		)
-	default:
-		if err := ctx.SaveFileToStorage(file, uploadedFile, types.Config.S3.Storage); err != nil {
-			log.Error().Caller().Err(err).Send()
+	// Providers cannot open encrypted files, they get a decrypted copy
+	workPrefix := strings.TrimSuffix(result.OutputPath, filepath.Ext(result.OutputPath))
+	inFile := result.InputPath
+	if result.Password != "" {
+		inFile = workPrefix + "_decrypted.pdf"
+		conf := model.NewDefaultConfiguration()
+		conf.UserPW = result.Password
+		conf.OwnerPW = result.Password
+		if err := api.DecryptFile(result.InputPath, inFile, conf); err != nil {
+			log.Error().Err(err).Caller().Send()
 			return helper.SendErrorResponse(
 				ctx,
 				fiber.StatusBadRequest,
-				fmt.Sprintf("failed save file to storage: %v", err),
+				helper.TransformPDFCPUErrorToResponse(err),
 			)
 		}
+		defer os.Remove(inFile)
 	}
 
-	mistralBody := struct {
-		Model    string `json:"model"`
-		Document struct {
-			Type        string `json:"type"`
-			DocumentURL string `json:"document_url"`
-		} `json:"document"`
-		IncludeImageBase64 bool `json:"include_image_base64"`
-	}{
-		Model: "mistral-ocr-latest",
-		Document: struct {
-			Type        string `json:"type"`
-			DocumentURL string `json:"document_url"`
-		}{
-			Type:        "document_url",
-			DocumentURL: fmt.Sprintf("https://%s/%s/%s", types.Config.S3.Endpoint, types.Config.S3.Bucket, uploadedFile),
-		},
-		IncludeImageBase64: true,
-	}
-
-	jsonBody, err := json.Marshal(mistralBody)
+	output, ocrPageNrs, err := ocrTextPages(ctx.Context(), inFile, forcedPages)
 	if err != nil {
-		log.Error().Caller().Err(err).Send()
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return helper.SendErrorResponse(
+				ctx,
+				fiber.StatusRequestTimeout,
+				"OCR cancelled",
+			)
+		}
+		log.Error().Err(err).Caller().Send()
 		return helper.SendErrorResponse(
 			ctx,
 			fiber.StatusBadRequest,
-			fmt.Sprintf("failed to marshalling json: %v", err),
+			helper.TransformPDFCPUErrorToResponse(err),
 		)
 	}
+	if output != nil && len(ocrPageNrs) == 0 {
+		return sendOCROutput(ctx, output, searchable, result.InputPath, result.Password, result.OutputPath, result.OutputName)
+	}
 
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", types.MistralOcrApiUrl, bytes.NewBuffer(jsonBody))
+	provider, err := newOCRProvider()
 	if err != nil {
+		log.Error().Err(err).Caller().Send()
 		return helper.SendErrorResponse(
 			ctx,
-			fiber.StatusBadRequest,
-			fmt.Sprintf("failed to create request: %v", err),
+			fiber.StatusServiceUnavailable,
+			"OCR is not available",
 		)
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", types.Config.Keys.Mistral))
-	req.Header.Set("User-Agent", types.AppName)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Error().Err(err).Caller().Send()
+	if searchable && !provider.locatesWords() {
 		return helper.SendErrorResponse(
 			ctx,
 			fiber.StatusBadRequest,
-			fmt.Sprintf("failed to get response: %v", err),
+			"The OCR provider does not locate words, searchable PDF output needs tesseract",
 		)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	// Only the pages without text are uploaded, unless that is all of them
+	ocrFile := inFile
+	if output != nil && len(ocrPageNrs) < len(output.Pages) {
+		ocrFile = workPrefix + "_pages.pdf"
+		selectedPages := make([]string, len(ocrPageNrs))
+		for i, pageNr := range ocrPageNrs {
+			selectedPages[i] = strconv.Itoa(pageNr)
+		}
+		if err := api.TrimFile(inFile, ocrFile, selectedPages, nil); err != nil {
+			log.Error().Err(err).Caller().Send()
+			return helper.SendErrorResponse(
+				ctx,
+				fiber.StatusInternalServerError,
+				helper.TransformPDFCPUErrorToResponse(err),
+			)
+		}
+		defer os.Remove(ocrFile)
+	}
+
+	recognized, err := provider.recognize(ctx.Context(), ocrFile)
 	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return helper.SendErrorResponse(
+				ctx,
+				fiber.StatusRequestTimeout,
+				"OCR cancelled",
+			)
+		}
 		log.Error().Err(err).Caller().Send()
 		return helper.SendErrorResponse(
 			ctx,
 			fiber.StatusBadRequest,
-			fmt.Sprintf("failed to read response body: %v", err),
+			err.Error(),
 		)
 	}
 
-	var output struct {
-		Pages []struct {
-			Index      int    `json:"index"`
-			Markdown   string `json:"markdown"`
-			Images     []any  `json:"images"`
-			Dimensions struct {
-				Dpi    int `json:"dpi"`
-				Height int `json:"height"`
-				Width  int `json:"width"`
-			} `json:"dimensions"`
-		} `json:"pages"`
-		UsageInfo struct {
-			PagesProcessed int `json:"pages_processed"`
-			DocSizeBytes   int `json:"doc_size_bytes"`
-		} `json:"usage_info"`
-	}
-
-	if err := json.Unmarshal(body, &output); err != nil {
+	if output == nil {
+		for i := range recognized.Pages {
+			recognized.Pages[i].Source = ocrSourceOCR
+		}
+		return sendOCROutput(ctx, recognized, searchable, result.InputPath, result.Password, result.OutputPath, result.OutputName)
+	}
+
+	// OCR pages are numbered within the uploaded pages
+	for _, page := range recognized.Pages {
+		if page.Index < 0 || page.Index >= len(ocrPageNrs) {
+			continue
+		}
+		page.Index = ocrPageNrs[page.Index] - 1
+		page.Source = ocrSourceOCR
+		output.Pages[page.Index] = page
+	}
+	output.UsageInfo = recognized.UsageInfo
+
+	return sendOCROutput(ctx, output, searchable, result.InputPath, result.Password, result.OutputPath, result.OutputName)
+}
+
+// sendOCROutput responds with output as JSON or, if searchable, with the PDF file inFile,
+// opened with password, as outputName, the recognized words added as a text layer.
+func sendOCROutput(ctx fiber.Ctx, output *ocrOutput, searchable bool, inFile, password, outputPath, outputName string) error {
+	if !searchable {
+		return ctx.JSON(types.Response{
+			Error: false,
+			Data:  output,
+		})
+	}
+
+	if err := addOCRTextLayer(inFile, password, outputPath, output.Pages); err != nil {
 		log.Error().Err(err).Caller().Send()
 		return helper.SendErrorResponse(
 			ctx,
-			fiber.StatusBadRequest,
-			fmt.Sprintf("failed to unmarshal response body: %v", err),
+			fiber.StatusInternalServerError,
+			helper.TransformPDFCPUErrorToResponse(err),
 		)
 	}
 
-	return ctx.JSON(types.Response{
-		Error: false,
-		Data:  output,
-	})
+	return ctx.Download(outputPath, outputName)
+}
+
+// ocrTextPages returns the pages of inFile with the text of those that have a text layer,
+// and the numbers of the pages left to OCR: the pages without text, those whose text can
+// not be read and forcedPages. The output is nil if inFile can not be read here at all,
+// the provider gets all of it then.
+func ocrTextPages(ctx context.Context, inFile string, forcedPages []string) (*ocrOutput, []int, error) {
+	pdfCtx, err := readFormContext(inFile, "", model.EXTRACTCONTENT)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to read text layer, sending all pages to OCR")
+		return nil, nil, nil
+	}
+	pb, err := pdfCtx.PageBoundaries(nil)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to read text layer, sending all pages to OCR")
+		return nil, nil, nil
+	}
+
+	forced := map[int]bool{}
+	if len(forcedPages) > 0 {
+		if forced, err = api.PagesForPageSelection(len(pb), forcedPages, true, true); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	doc, closeDoc, err := openRenderDocument(ctx, pdfCtx, inFile, false)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, err
+		}
+		log.Warn().Err(err).Msg("failed to read text layer, sending all pages to OCR")
+		return nil, nil, nil
+	}
+	defer closeDoc()
+
+	output := &ocrOutput{Pages: make([]ocrPage, len(pb))}
+	var ocrPageNrs []int
+	for i := range pb {
+		page := &output.Pages[i]
+		page.Index = i
+		page.Images = []any{}
+
+		// The text layer is measured at 72 dpi, one pixel per point
+		w, h := pb[i].CropBox().Width(), pb[i].CropBox().Height()
+		if pb[i].Rot%180 != 0 {
+			w, h = h, w
+		}
+		page.Dimensions.Dpi = 72
+		page.Dimensions.Width, page.Dimensions.Height = int(math.Round(w)), int(math.Round(h))
+
+		text, err := doc.PageText(ctx, i+1, false)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, err
+			}
+			log.Warn().Err(err).Int("page", i+1).Msg("failed to read text layer, sending page to OCR")
+		}
+		if err != nil || forced[i+1] || visibleChars(text) < minPageTextChars {
+			ocrPageNrs = append(ocrPageNrs, i+1)
+			continue
+		}
+		page.Markdown = text
+		page.Source = ocrSourceText
+	}
+
+	return output, ocrPageNrs, nil
+}
+
+func visibleChars(s string) int {
+	n := 0
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			n++
+		}
+	}
+	return n
 }
@@ -0,0 +1,294 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"pdftool/types"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/rs/zerolog/log"
+)
+
+// OCR providers, chosen by types.Config.OCR.Provider.
+const (
+	ocrProviderMistral   = "mistral"
+	ocrProviderTesseract = "tesseract"
+)
+
+// ocrProvider recognizes the text of scanned PDF pages.
+type ocrProvider interface {
+	// recognize returns the text of every page of the PDF file, page indexes counting
+	// from 0 within the file.
+	recognize(ctx context.Context, file string) (*ocrOutput, error)
+	// locatesWords reports whether recognized pages come with the boxes of their words.
+	locatesWords() bool
+}
+
+// newOCRProvider returns the configured OCR provider.
+func newOCRProvider() (ocrProvider, error) {
+	switch strings.ToLower(types.Config.OCR.Provider) {
+	case ocrProviderMistral:
+		if types.Config.Keys.Mistral == "" {
+			return nil, fmt.Errorf("OCR provider %s needs a key", ocrProviderMistral)
+		}
+		return mistralOCR{inline: types.Config.OCR.Inline || !types.Config.S3.Enable}, nil
+	case ocrProviderTesseract:
+		path, err := exec.LookPath(types.Config.OCR.Tesseract.Path)
+		if err != nil {
+			return nil, fmt.Errorf("OCR provider %s not found, the default image has none, use its tesseract variant: %v", ocrProviderTesseract, err)
+		}
+		return tesseractOCR{path: path}, nil
+	}
+	return nil, fmt.Errorf("unknown OCR provider %q", types.Config.OCR.Provider)
+}
+
+// OCREnabled reports whether the configured OCR provider can be used.
+func OCREnabled() bool {
+	if _, err := newOCRProvider(); err != nil {
+		log.Warn().Err(err).Msg("OCR disabled")
+		return false
+	}
+	return true
+}
+
+// mistralOCR runs the Mistral OCR API on files it shares through S3 for the time of the
+// request, or sends in the request as a data URL if inline.
+type mistralOCR struct {
+	inline bool
+}
+
+func (mistralOCR) locatesWords() bool { return false }
+
+func (m mistralOCR) recognize(ctx context.Context, file string) (*ocrOutput, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var documentURL string
+	if m.inline {
+		documentURL = "data:application/pdf;base64," + base64.StdEncoding.EncodeToString(content)
+	} else {
+		// The object is private, Mistral reads it through a short-lived presigned URL. Its key is
+		// random, names of concurrent requests may be the same
+		uploadedFile := rand.Text() + strings.ToLower(filepath.Ext(file))
+		if err := types.Config.S3.Storage.Set(uploadedFile, content, 0); err != nil {
+			return nil, fmt.Errorf("failed save file to storage: %v", err)
+		}
+		defer func() {
+			if err := types.Config.S3.Storage.Delete(uploadedFile); err != nil {
+				log.Warn().Err(err).Str("object", uploadedFile).Msg("failed to remove file from storage")
+			}
+		}()
+
+		u, err := types.Config.S3.Storage.Conn().PresignedGetObject(ctx, types.Config.S3.Bucket, uploadedFile, types.Config.S3.PresignExpiry, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to presign file url: %v", err)
+		}
+		documentURL = u.String()
+	}
+
+	mistralBody := struct {
+		Model    string `json:"model"`
+		Document struct {
+			Type        string `json:"type"`
+			DocumentURL string `json:"document_url"`
+		} `json:"document"`
+		IncludeImageBase64 bool `json:"include_image_base64"`
+	}{
+		Model: "mistral-ocr-latest",
+		Document: struct {
+			Type        string `json:"type"`
+			DocumentURL string `json:"document_url"`
+		}{
+			Type:        "document_url",
+			DocumentURL: documentURL,
+		},
+		IncludeImageBase64: true,
+	}
+
+	jsonBody, err := json.Marshal(mistralBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshalling json: %v", err)
+	}
+
+	client := &http.Client{}
+	req, err := http.NewRequestWithContext(ctx, "POST", types.MistralOcrApiUrl, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", types.Config.Keys.Mistral))
+	req.Header.Set("User-Agent", types.AppName)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCR request failed with status %d: %s", resp.StatusCode, body)
+	}
+
+	var output ocrOutput
+	if err := json.Unmarshal(body, &output); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %v", err)
+	}
+
+	return &output, nil
+}
+
+// tesseractOCR runs the tesseract command at path on the pages rendered to images, so
+// documents never leave the server.
+type tesseractOCR struct {
+	path string
+}
+
+func (tesseractOCR) locatesWords() bool { return true }
+
+func (t tesseractOCR) recognize(ctx context.Context, file string) (*ocrOutput, error) {
+	pdfCtx, err := readFormContext(file, "", model.EXTRACTCONTENT)
+	if err != nil {
+		return nil, err
+	}
+	pb, err := pdfCtx.PageBoundaries(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	dpi := types.Config.OCR.Tesseract.DPI
+	if dpi < 1 || dpi > maxRenderDPI {
+		dpi = defaultRenderDPI
+	}
+	pageNrs, scales, err := renderScales(pb, nil, dpi, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	pagesDir, err := os.MkdirTemp("/tmp", "tesseract-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(pagesDir)
+
+	// Pages are rendered with their annotations, as they are seen
+	doc, closeDoc, err := openRenderDocument(ctx, pdfCtx, file, true)
+	if err != nil {
+		return nil, err
+	}
+	defer closeDoc()
+
+	output := &ocrOutput{Pages: make([]ocrPage, 0, len(pageNrs))}
+	for i, pageNr := range pageNrs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		imagePath := filepath.Join(pagesDir, fmt.Sprintf("page_%d.png", pageNr))
+		w, h, err := doc.RenderPage(ctx, pageNr, scales[i]*72, imagePath, 0)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, fmt.Errorf("failed to render page %d: %v", pageNr, err)
+		}
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, t.path, imagePath, "stdout",
+			"-l", types.Config.OCR.Tesseract.Lang, "--dpi", strconv.Itoa(dpi), "tsv")
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, fmt.Errorf("tesseract failed on page %d: %v: %s", pageNr, err, strings.TrimSpace(stderr.String()))
+		}
+
+		page := parseTesseractTSV(stdout.String())
+		page.Index = pageNr - 1
+		page.Dimensions.Dpi = dpi
+		page.Dimensions.Width, page.Dimensions.Height = w, h
+		output.Pages = append(output.Pages, page)
+		reportJobProgress(ctx, i+1, len(pageNrs))
+	}
+
+	if info, err := os.Stat(file); err == nil {
+		output.UsageInfo.DocSizeBytes = int(info.Size())
+	}
+	output.UsageInfo.PagesProcessed = len(output.Pages)
+
+	return output, nil
+}
+
+// parseTesseractTSV returns the page read from tesseract's TSV output, which lists the
+// words with their boxes and the block, paragraph and line each belongs to. Lines are
+// put on their own lines and paragraphs separated by a blank line.
+func parseTesseractTSV(tsv string) ocrPage {
+	page := ocrPage{Images: []any{}}
+
+	var sb strings.Builder
+	var lastPar, lastLine string
+	for i, row := range strings.Split(tsv, "\n") {
+		// level page_num block_num par_num line_num word_num left top width height conf text
+		cols := strings.SplitN(strings.TrimRight(row, "\r"), "\t", 12)
+		if i == 0 || len(cols) < 12 || cols[0] != "5" {
+			continue
+		}
+		text := strings.TrimSpace(cols[11])
+		if text == "" {
+			continue
+		}
+
+		var box [4]float64
+		valid := true
+		for j := range box {
+			v, err := strconv.ParseFloat(cols[6+j], 64)
+			if err != nil {
+				valid = false
+				break
+			}
+			box[j] = v
+		}
+		if !valid {
+			continue
+		}
+
+		par, line := cols[2]+"."+cols[3], cols[2]+"."+cols[3]+"."+cols[4]
+		switch {
+		case sb.Len() == 0:
+		case par != lastPar:
+			sb.WriteString("\n\n")
+		case line != lastLine:
+			sb.WriteByte('\n')
+		default:
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(text)
+		lastPar, lastLine = par, line
+
+		page.words = append(page.words, ocrWord{text: text, left: box[0], top: box[1], width: box[2], height: box[3]})
+	}
+
+	page.Markdown = sb.String()
+	return page
+}
@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pdftool/internal/pdftest"
+	"slices"
+	"testing"
+)
+
+func TestOCRTextPages(t *testing.T) {
+	var b pdftest.Builder
+	font := b.Add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	contents := []string{
+		"BT /F1 12 Tf 20 100 Td (This page has a text layer) Tj ET",
+		"BT /F1 12 Tf 20 100 Td (This content stream is broken Tj ET",
+		"0 0 1 rg 20 20 100 100 re f",
+	}
+	pages := make([]string, len(contents))
+	for i, content := range contents {
+		pages[i] = fmt.Sprintf("/MediaBox [0 0 200 200] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R",
+			font, b.Add(pdftest.Stream("", content)))
+	}
+	file := b.Write(t, b.Document(pages...))
+
+	output, ocrPageNrs, err := ocrTextPages(context.Background(), file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{2, 3}; !slices.Equal(ocrPageNrs, want) {
+		t.Errorf("got pages to OCR %v, want %v", ocrPageNrs, want)
+	}
+	if page := output.Pages[0]; page.Source != ocrSourceText || page.Markdown != "This page has a text layer\n" {
+		t.Errorf("got page 1 %q from %q", page.Markdown, page.Source)
+	}
+
+	output, ocrPageNrs, err = ocrTextPages(context.Background(), file, []string{"1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2, 3}; !slices.Equal(ocrPageNrs, want) {
+		t.Errorf("got pages to OCR %v with page 1 forced, want %v", ocrPageNrs, want)
+	}
+
+	// A file that can not be read here is left to the provider as a whole
+	broken := filepath.Join(t.TempDir(), "broken.pdf")
+	if err := os.WriteFile(broken, []byte("%PDF-1.7\nnot really"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	output, ocrPageNrs, err = ocrTextPages(context.Background(), broken, nil)
+	if err != nil || output != nil || ocrPageNrs != nil {
+		t.Errorf("got output %v, pages %v, error %v for a file that can not be read", output, ocrPageNrs, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := ocrTextPages(ctx, file, nil); err == nil {
+		t.Error("cancelled inspection succeeded")
+	}
+}
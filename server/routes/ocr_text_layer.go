@@ -0,0 +1,222 @@
+package routes
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Metrics of the text layer font in glyph space units. Every glyph has the same width,
+// words are stretched to fit their box, and ascent and descent add up to the box height.
+const (
+	ocrFontWidth   = 500
+	ocrFontAscent  = 800
+	ocrFontDescent = -200
+)
+
+// addOCRTextLayer writes inFile, opened with password, to outFile with the recognized
+// words of pages laid over them as invisible text, so that scanned pages can be searched
+// and their text selected. Pages without words are left as they are.
+func addOCRTextLayer(inFile, password, outFile string, pages []ocrPage) error {
+	pdfCtx, err := readFormContext(inFile, password, model.ADDWATERMARKS)
+	if err != nil {
+		return err
+	}
+	pb, err := pdfCtx.PageBoundaries(nil)
+	if err != nil {
+		return err
+	}
+
+	// Glyphs are numbered in the order their characters first appear, the font shared
+	// by all pages maps them back to Unicode once they are known
+	type layer struct {
+		fonts   types.Dict
+		name    string
+		content []byte
+		dict    types.Dict
+	}
+	var layers []layer
+	cids := map[rune]int{}
+	fontNr := 0
+
+	for _, page := range pages {
+		pageNr := page.Index + 1
+		if len(page.words) == 0 || pageNr < 1 || pageNr > len(pb) || page.Dimensions.Dpi <= 0 {
+			continue
+		}
+
+		pageDict, _, inhAttrs, err := pdfCtx.PageDict(pageNr, false)
+		if err != nil {
+			return err
+		}
+		fonts, err := pageResources(pdfCtx, pageDict, inhAttrs, "Font")
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprintf("OCRText%d", fontNr)
+		for _, taken := fonts[name]; taken; _, taken = fonts[name] {
+			fontNr++
+			name = fmt.Sprintf("OCRText%d", fontNr)
+		}
+
+		// Word boxes are in pixels of the page rendered at the page's dpi
+		toUser := pageSpace(pb[pageNr-1].CropBox(), pb[pageNr-1].Rot, float64(page.Dimensions.Dpi)/72).inverse()
+
+		var content strings.Builder
+		fmt.Fprintf(&content, "BT 3 Tr /%s 1 Tf\n", name)
+		for _, word := range page.words {
+			runes := []rune(word.text)
+			if len(runes) == 0 || word.width <= 0 || word.height <= 0 {
+				continue
+			}
+
+			var hex strings.Builder
+			for _, r := range runes {
+				cid, ok := cids[r]
+				if !ok {
+					cid = len(cids) + 1
+					cids[r] = cid
+				}
+				fmt.Fprintf(&hex, "%04X", cid)
+			}
+
+			// Glyph space is stretched over the word box with the baseline at the ascent,
+			// then taken from device space to user space
+			h := word.height * 1000 / (ocrFontAscent - ocrFontDescent)
+			w := word.width * 1000 / (ocrFontWidth * float64(len(runes)))
+			tm := matrix{w, 0, 0, -h, word.left, word.top + h*ocrFontAscent/1000}.multiply(toUser)
+			fmt.Fprintf(&content, "%.4f %.4f %.4f %.4f %.4f %.4f Tm <%s> Tj\n", tm[0], tm[1], tm[2], tm[3], tm[4], tm[5], hex.String())
+		}
+		content.WriteString("ET\n")
+
+		layers = append(layers, layer{fonts: fonts, name: name, content: []byte(content.String()), dict: pageDict})
+	}
+
+	if len(layers) > 0 {
+		fontRef, err := ocrFont(pdfCtx, cids)
+		if err != nil {
+			return err
+		}
+		for _, l := range layers {
+			l.fonts[l.name] = *fontRef
+			if err := wrapPageContent(pdfCtx, l.dict, l.content); err != nil {
+				return err
+			}
+		}
+	}
+
+	return api.WriteContextFile(pdfCtx, outFile)
+}
+
+// pageSpace returns the matrix mapping the default user space of a page with the crop box
+// and rotation to the pixels of the page rendered at scale, with the origin top left.
+func pageSpace(box *types.Rectangle, rotate int, scale float64) matrix {
+	w, h := box.Width(), box.Height()
+	m := matrix{1, 0, 0, 1, -box.LL.X, -box.LL.Y}
+	switch (rotate%360 + 360) % 360 {
+	case 90:
+		m = m.multiply(matrix{0, -1, 1, 0, 0, w})
+		h = w
+	case 180:
+		m = m.multiply(matrix{-1, 0, 0, -1, w, h})
+	case 270:
+		m = m.multiply(matrix{0, 1, -1, 0, h, 0})
+		h = w
+	}
+	return m.multiply(matrix{scale, 0, 0, -scale, 0, h * scale})
+}
+
+// ocrFont adds a composite font with no glyphs of its own, which is fine for text that
+// is never painted, whose glyph identifiers cids map to their characters.
+func ocrFont(pdfCtx *model.Context, cids map[rune]int) (*types.IndirectRef, error) {
+	descriptor, err := pdfCtx.IndRefForNewObject(types.Dict{
+		"Type":        types.Name("FontDescriptor"),
+		"FontName":    types.Name("GlyphLessFont"),
+		"Flags":       types.Integer(4),
+		"FontBBox":    types.Array{types.Integer(0), types.Integer(ocrFontDescent), types.Integer(ocrFontWidth), types.Integer(ocrFontAscent)},
+		"ItalicAngle": types.Integer(0),
+		"Ascent":      types.Integer(ocrFontAscent),
+		"Descent":     types.Integer(ocrFontDescent),
+		"CapHeight":   types.Integer(ocrFontAscent),
+		"StemV":       types.Integer(80),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cidFont, err := pdfCtx.IndRefForNewObject(types.Dict{
+		"Type":     types.Name("Font"),
+		"Subtype":  types.Name("CIDFontType2"),
+		"BaseFont": types.Name("GlyphLessFont"),
+		"CIDSystemInfo": types.Dict{
+			"Registry":   types.StringLiteral("Adobe"),
+			"Ordering":   types.StringLiteral("Identity"),
+			"Supplement": types.Integer(0),
+		},
+		"FontDescriptor": *descriptor,
+		"DW":             types.Integer(ocrFontWidth),
+		"CIDToGIDMap":    types.Name("Identity"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sd, err := pdfCtx.NewStreamDictForBuf(ocrToUnicode(cids))
+	if err != nil {
+		return nil, err
+	}
+	if err := sd.Encode(); err != nil {
+		return nil, err
+	}
+	toUnicode, err := pdfCtx.IndRefForNewObject(*sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return pdfCtx.IndRefForNewObject(types.Dict{
+		"Type":            types.Name("Font"),
+		"Subtype":         types.Name("Type0"),
+		"BaseFont":        types.Name("GlyphLessFont"),
+		"Encoding":        types.Name("Identity-H"),
+		"DescendantFonts": types.Array{*cidFont},
+		"ToUnicode":       *toUnicode,
+	})
+}
+
+// ocrToUnicode returns the ToUnicode CMap mapping the two byte codes of cids to their
+// characters.
+func ocrToUnicode(cids map[rune]int) []byte {
+	runes := make([]rune, 0, len(cids))
+	for r := range cids {
+		runes = append(runes, r)
+	}
+	slices.SortFunc(runes, func(a, b rune) int { return cids[a] - cids[b] })
+
+	var sb strings.Builder
+	sb.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	sb.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	sb.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	sb.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+
+	// A bfchar section holds at most 100 mappings
+	for start := 0; start < len(runes); start += 100 {
+		end := min(start+100, len(runes))
+		fmt.Fprintf(&sb, "%d beginbfchar\n", end-start)
+		for _, r := range runes[start:end] {
+			fmt.Fprintf(&sb, "<%04X> <", cids[r])
+			for _, u := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&sb, "%04X", u)
+			}
+			sb.WriteString(">\n")
+		}
+		sb.WriteString("endbfchar\n")
+	}
+
+	sb.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return []byte(sb.String())
+}
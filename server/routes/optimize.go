@@ -1,22 +1,78 @@
 package routes
 
 import (
+	"context"
+	"errors"
+	"os"
 	"pdftool/server/helper"
+	"strconv"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
 	"github.com/rs/zerolog/log"
 )
 
+// optimizeProfile holds the image settings of a named optimize profile and whether
+// it removes unused resources.
+type optimizeProfile struct {
+	ImageDPI     int
+	JPEGQuality  int
+	RemoveUnused bool
+}
+
+// optimizeProfiles are the presets of the profile option, lossless being the default.
+var optimizeProfiles = map[string]optimizeProfile{
+	"lossless": {},
+	"web":      {ImageDPI: 200, JPEGQuality: 85, RemoveUnused: true},
+	"ebook":    {ImageDPI: 150, JPEGQuality: 75, RemoveUnused: true},
+	"screen":   {ImageDPI: 72, JPEGQuality: 50, RemoveUnused: true},
+}
+
+// resourceCategories are the resource dict entries content streams refer to by name.
+var resourceCategories = []string{"ExtGState", "ColorSpace", "Pattern", "Shading", "XObject", "Font", "Properties"}
+
+type optimizeOptions struct {
+	Profile            string `json:"profile" form:"profile"`
+	ImageDPI           int    `json:"image_dpi" form:"image_dpi"`
+	JPEGQuality        int    `json:"jpeg_quality" form:"jpeg_quality"`
+	Dedup              *bool  `json:"dedup" form:"dedup"`
+	RemoveUnused       *bool  `json:"remove_unused" form:"remove_unused"`
+	FlattenForms       bool   `json:"flatten_forms" form:"flatten_forms"`
+	FlattenAnnotations bool   `json:"flatten_annotations" form:"flatten_annotations"`
+	RemoveAnnotations  bool   `json:"remove_annotations" form:"remove_annotations"`
+	RemoveJavaScript   bool   `json:"remove_javascript" form:"remove_javascript"`
+	RemoveOpenActions  bool   `json:"remove_open_actions" form:"remove_open_actions"`
+	PrintReady         bool   `json:"print_ready" form:"print_ready"` // all of the above
+	Linearize          bool   `json:"linearize" form:"linearize"`
+}
+
 // @Summary Optimize a PDF file
-// @Description Optimize a PDF file
+// @Description Optimize a PDF file. A profile (lossless, web, ebook or screen) sets the image resolution and JPEG quality,
+// @Description image_dpi and jpeg_quality override it. The original and optimized sizes in bytes are returned
+// @Description in the X-Original-Size and X-Optimized-Size headers. linearize writes a file viewers can show
+// @Description before it is fully downloaded. Optionally flattens form fields and annotations into the page content
+// @Description and strips annotations, JavaScript and open actions. print_ready turns on all of these.
 // @Tags PDF Operations
 // @Accept multipart/form-data,application/json
 // @Produce octet-stream
 // @Security ApiKeyAuth
 // @Param file formData file false "PDF file to optimize"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
 // @Param request body object false "JSON request with base64 PDF"
+// @Param profile formData string false "lossless (default), web (200 dpi, quality 85), ebook (150 dpi, quality 75) or screen (72 dpi, quality 50)"
+// @Param image_dpi formData int false "Downsample images drawn at a higher resolution to this many dpi"
+// @Param jpeg_quality formData int false "Re-encode images as JPEG of this quality (1-100)"
+// @Param dedup formData bool false "Merge duplicate fonts, images and content streams, defaults to true"
+// @Param remove_unused formData bool false "Remove fonts, images and other resources no page uses, defaults to true for the web, ebook and screen profiles"
+// @Param flatten_forms formData bool false "Draw form fields into the page content and remove the form"
+// @Param flatten_annotations formData bool false "Draw all annotations, including form fields, into the page content"
+// @Param remove_annotations formData bool false "Remove all annotations except form fields"
+// @Param remove_javascript formData bool false "Remove document, page, field and link JavaScript"
+// @Param remove_open_actions formData bool false "Remove the open action and document level additional actions"
+// @Param print_ready formData bool false "Flatten everything and remove all interactive elements"
+// @Param linearize formData bool false "Write a linearized file (fast web view) that viewers can show before it is fully downloaded"
 // @Success 200 {file} binary
 // @Failure 400 {object} types.Response
 // @Failure 401 {object} types.Response
@@ -32,27 +88,508 @@ func Optimize(ctx fiber.Ctx) error {
 	}
 	defer result.Cleanup()
 
-	if err := api.ValidateFile(result.InputPath, nil); err != nil {
-		return helper.SendErrorResponse(
-			ctx,
-			fiber.StatusBadRequest,
-			"File is invalid or corrupted. Please upload a valid PDF.",
-		)
+	var opts optimizeOptions
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid optimize options")
+	}
+
+	if stepErr := optimizePDF(ctx.Context(), result.InputPath, result.OutputPath, result.Password, opts); stepErr != nil {
+		return helper.SendErrorResponse(ctx, stepErr.code, stepErr.message)
 	}
 
-	conf := model.NewDefaultConfiguration()
+	inStat, statErr := os.Stat(result.InputPath)
+	if statErr != nil {
+		log.Error().Err(statErr).Caller().Send()
+		return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, "Failed to optimize PDF.")
+	}
+
+	outStat, statErr := os.Stat(result.OutputPath)
+	if statErr != nil {
+		log.Error().Err(statErr).Caller().Send()
+		return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, "Failed to optimize PDF.")
+	}
+
+	ctx.Set("X-Original-Size", strconv.FormatInt(inStat.Size(), 10))
+	ctx.Set("X-Optimized-Size", strconv.FormatInt(outStat.Size(), 10))
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// optimizePDF optimizes inFile, opened with password, as opts tell and writes outFile.
+func optimizePDF(ctx context.Context, inFile, outFile, password string, opts optimizeOptions) *stepError {
+	if _, found := optimizeProfiles[opts.Profile]; !found && opts.Profile != "" {
+		return newStepError(fiber.StatusBadRequest, "Invalid profile. Use lossless, web, ebook or screen")
+	}
+
+	if opts.ImageDPI < 0 || opts.ImageDPI > 2400 {
+		return newStepError(fiber.StatusBadRequest, "Image DPI must be between 1 and 2400")
+	}
+
+	if opts.JPEGQuality < 0 || opts.JPEGQuality > 100 {
+		return newStepError(fiber.StatusBadRequest, "JPEG quality must be between 1 and 100")
+	}
+
+	if err := api.ValidateFile(inFile, passwordConfiguration(password)); err != nil {
+		return newStepError(fiber.StatusBadRequest, "File is invalid, corrupted or the password is wrong.")
+	}
+
+	if err := optimizeFile(ctx, inFile, outFile, password, opts); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return newStepError(fiber.StatusRequestTimeout, "Optimization cancelled")
+		}
+		if errors.Is(err, errLinearizeEncrypted) {
+			return newStepError(fiber.StatusBadRequest, "Encrypted PDFs can not be linearized. Decrypt the file first.")
+		}
+		log.Error().Err(err).Caller().Send()
+		return newStepError(fiber.StatusInternalServerError, "Failed to optimize PDF.")
+	}
+
+	return nil
+}
+
+// optimizeFile optimizes inFile, opened with password, following the profile and applies
+// the flatten and remove options before writing outFile. It stops with the error of ctx
+// once ctx is done.
+func optimizeFile(ctx context.Context, inFile, outFile, password string, opts optimizeOptions) error {
+	profile := optimizeProfiles[opts.Profile]
+	if opts.ImageDPI == 0 {
+		opts.ImageDPI = profile.ImageDPI
+	}
+	if opts.JPEGQuality == 0 {
+		opts.JPEGQuality = profile.JPEGQuality
+	}
+	if opts.RemoveUnused == nil {
+		opts.RemoveUnused = &profile.RemoveUnused
+	}
+
+	if opts.PrintReady {
+		opts.FlattenAnnotations = true
+		opts.RemoveJavaScript = true
+		opts.RemoveOpenActions = true
+	}
+
+	f, err := os.Open(inFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	conf := passwordConfiguration(password)
+	conf.Cmd = model.OPTIMIZE
 	conf.Optimize = true
 	conf.OptimizeDuplicateContentStreams = true
 	conf.OptimizeResourceDicts = true
+	if opts.Dedup != nil && !*opts.Dedup {
+		conf.Cmd = model.VALIDATE
+		conf.Optimize = false
+		conf.OptimizeDuplicateContentStreams = false
+	}
 
-	if err := api.OptimizeFile(result.InputPath, result.OutputPath, conf); err != nil {
-		log.Error().Err(err).Caller().Send()
-		return helper.SendErrorResponse(
-			ctx,
-			fiber.StatusInternalServerError,
-			"Failed to optimize PDF.",
-		)
+	pdfCtx, err := api.ReadValidateAndOptimize(f, conf)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	return ctx.Download(result.OutputPath, result.OutputName)
+	switch {
+	case opts.FlattenAnnotations:
+		if err := flattenAnnotations(pdfCtx, func(string) bool { return true }); err != nil {
+			return err
+		}
+		removeForm(pdfCtx)
+	case opts.FlattenForms:
+		if err := flattenForm(pdfCtx); err != nil {
+			return err
+		}
+	}
+
+	if opts.RemoveAnnotations {
+		if err := removeAnnotations(pdfCtx, func(subtype string) bool { return subtype != "Widget" }); err != nil {
+			return err
+		}
+	}
+
+	if opts.RemoveJavaScript {
+		if err := removeJavaScript(pdfCtx); err != nil {
+			return err
+		}
+	}
+
+	if opts.RemoveOpenActions {
+		delete(pdfCtx.RootDict, "OpenAction")
+		delete(pdfCtx.RootDict, "AA")
+	}
+
+	if opts.ImageDPI > 0 || opts.JPEGQuality > 0 {
+		if err := compressImages(ctx, pdfCtx, opts.ImageDPI, opts.JPEGQuality); err != nil {
+			return err
+		}
+	}
+
+	if *opts.RemoveUnused {
+		if err := removeUnusedResources(ctx, pdfCtx); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if opts.Linearize {
+		return writeLinearizedFile(pdfCtx, outFile)
+	}
+
+	return api.WriteContextFile(pdfCtx, outFile)
+}
+
+// removeUnusedResources drops the resources no content stream uses, leaving their objects
+// unreferenced so they are not written. It walks the content streams drawn from the pages
+// and prunes the resource dicts they are drawn with. Names are collected from all those
+// streams together, a resource stays as long as any of them uses its name. It stops with
+// the error of ctx once ctx is done.
+func removeUnusedResources(ctx context.Context, pdfCtx *model.Context) error {
+	use := resourceUse{ctx: ctx, pdfCtx: pdfCtx, names: map[string]bool{}, seen: map[int]bool{}}
+	for pageNr := 1; pageNr <= pdfCtx.PageCount; pageNr++ {
+		if err := use.page(pageNr); err != nil {
+			// Without knowing what a stream uses, nothing can be removed safely
+			if errors.Is(err, errUnknownContent) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	for _, res := range use.resources {
+		for _, category := range resourceCategories {
+			names, err := pdfCtx.DereferenceDict(res[category])
+			if err != nil || names == nil {
+				continue
+			}
+			for name := range names {
+				if !use.names[name] {
+					delete(names, name)
+				}
+			}
+			if len(names) == 0 {
+				delete(res, category)
+			}
+		}
+	}
+
+	return nil
+}
+
+// errUnknownContent reports a content stream whose resource use is not known.
+var errUnknownContent = errors.New("content stream can not be parsed")
+
+// resourceUse collects the resource names used by the content streams drawn from the pages
+// of a document: page contents, annotation appearances, form XObjects, tiling patterns,
+// soft mask groups and Type 3 glyph procedures.
+type resourceUse struct {
+	ctx    context.Context
+	pdfCtx *model.Context
+	names  map[string]bool
+	// resources are the resource dicts the streams are drawn with
+	resources []types.Dict
+	// seen holds the object numbers of the streams walked
+	seen map[int]bool
+}
+
+// page walks the content and the annotation appearances of page pageNr.
+func (u *resourceUse) page(pageNr int) error {
+	pageDict, _, inhAttrs, err := u.pdfCtx.PageDict(pageNr, false)
+	if err != nil {
+		return err
+	}
+
+	res, err := u.pdfCtx.DereferenceDict(pageDict["Resources"])
+	if err != nil {
+		return err
+	}
+	if res == nil && inhAttrs != nil {
+		res = inhAttrs.Resources
+	}
+
+	content, err := u.pdfCtx.PageContent(pageDict)
+	if err != nil && !errors.Is(err, model.ErrNoContent) {
+		return errUnknownContent
+	}
+	if err := u.content(content, res, 0); err != nil {
+		return err
+	}
+
+	annots, err := u.pdfCtx.DereferenceArray(pageDict["Annots"])
+	if err != nil {
+		return err
+	}
+	for _, o := range annots {
+		annot, err := u.pdfCtx.DereferenceDict(o)
+		if err != nil || annot == nil {
+			continue
+		}
+		ap, err := u.pdfCtx.DereferenceDict(annot["AP"])
+		if err != nil || ap == nil {
+			continue
+		}
+		// Each appearance is a stream or a dict of streams by appearance state
+		for _, key := range []string{"N", "R", "D"} {
+			appearances := types.Array{ap[key]}
+			if states, err := u.pdfCtx.DereferenceDict(ap[key]); err == nil && states != nil {
+				appearances = appearances[:0]
+				for _, o := range states {
+					appearances = append(appearances, o)
+				}
+			}
+			for _, o := range appearances {
+				if err := u.stream(o, nil, 0); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// stream walks the content stream o, drawn with its own resources or else with parentRes.
+// Streams are walked once.
+func (u *resourceUse) stream(o types.Object, parentRes types.Dict, depth int) error {
+	indRef, ok := o.(types.IndirectRef)
+	if !ok || u.seen[indRef.ObjectNumber.Value()] {
+		return nil
+	}
+	u.seen[indRef.ObjectNumber.Value()] = true
+
+	if err := u.ctx.Err(); err != nil {
+		return err
+	}
+	if depth >= maxFormDepth {
+		return errUnknownContent
+	}
+
+	sd, _, err := u.pdfCtx.DereferenceStreamDict(indRef)
+	if err != nil || sd == nil {
+		return nil
+	}
+	if err := sd.Decode(); err != nil {
+		return errUnknownContent
+	}
+	res, err := u.pdfCtx.DereferenceDict(sd.Dict["Resources"])
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		res = parentRes
+	}
+
+	return u.content(sd.Content, res, depth)
+}
+
+// content records the names content uses and walks the streams res holds.
+func (u *resourceUse) content(content []byte, res types.Dict, depth int) error {
+	ops, err := parseContent(content)
+	if err != nil {
+		return errUnknownContent
+	}
+
+	var collect func(o types.Object)
+	collect = func(o types.Object) {
+		switch o := o.(type) {
+		case types.Name:
+			u.names[o.Value()] = true
+		case types.Array:
+			for _, v := range o {
+				collect(v)
+			}
+		case types.Dict:
+			for _, v := range o {
+				collect(v)
+			}
+		}
+	}
+	for _, op := range ops {
+		for _, o := range op.Operands {
+			collect(o)
+		}
+	}
+
+	if res == nil {
+		return nil
+	}
+	u.resources = append(u.resources, res)
+
+	return u.resourceStreams(res, depth)
+}
+
+// resourceStreams walks the content streams of the resources in res: form XObjects,
+// tiling patterns, the groups of soft masks and the glyph procedures of Type 3 fonts.
+func (u *resourceUse) resourceStreams(res types.Dict, depth int) error {
+	entries := func(category string) types.Dict {
+		d, err := u.pdfCtx.DereferenceDict(res[category])
+		if err != nil {
+			return nil
+		}
+		return d
+	}
+
+	for _, o := range entries("XObject") {
+		sd, _, err := u.pdfCtx.DereferenceStreamDict(o)
+		if err != nil || sd == nil {
+			continue
+		}
+		if subtype := sd.Dict.Subtype(); subtype != nil && *subtype == "Form" {
+			if err := u.stream(o, res, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, o := range entries("Pattern") {
+		sd, _, err := u.pdfCtx.DereferenceStreamDict(o)
+		if err != nil || sd == nil {
+			// Shading patterns are dicts without content
+			continue
+		}
+		if err := u.stream(o, res, depth+1); err != nil {
+			return err
+		}
+	}
+
+	for _, o := range entries("ExtGState") {
+		gs, err := u.pdfCtx.DereferenceDict(o)
+		if err != nil || gs == nil {
+			continue
+		}
+		smask, err := u.pdfCtx.DereferenceDict(gs["SMask"])
+		if err != nil || smask == nil {
+			continue
+		}
+		if err := u.stream(smask["G"], res, depth+1); err != nil {
+			return err
+		}
+	}
+
+	for _, o := range entries("Font") {
+		font, err := u.pdfCtx.DereferenceDict(o)
+		if err != nil || font == nil || font.Subtype() == nil || *font.Subtype() != "Type3" {
+			continue
+		}
+		fontRes, err := u.pdfCtx.DereferenceDict(font["Resources"])
+		if err != nil {
+			continue
+		}
+		if fontRes == nil {
+			fontRes = res
+		}
+		procs, err := u.pdfCtx.DereferenceDict(font["CharProcs"])
+		if err != nil {
+			continue
+		}
+		for _, proc := range procs {
+			if err := u.stream(proc, fontRes, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeAnnotations removes the annotations whose subtype matches from every page.
+func removeAnnotations(pdfCtx *model.Context, match func(subtype string) bool) error {
+	for pageNr := 1; pageNr <= pdfCtx.PageCount; pageNr++ {
+		pageDict, _, _, err := pdfCtx.PageDict(pageNr, false)
+		if err != nil {
+			return err
+		}
+
+		annots, err := pdfCtx.DereferenceArray(pageDict["Annots"])
+		if err != nil || len(annots) == 0 {
+			continue
+		}
+
+		var kept types.Array
+		for _, o := range annots {
+			d, err := pdfCtx.DereferenceDict(o)
+			if err != nil || d == nil {
+				continue
+			}
+			if subtype := d.NameEntry("Subtype"); subtype != nil && match(*subtype) {
+				continue
+			}
+			kept = append(kept, o)
+		}
+
+		if len(kept) == 0 {
+			delete(pageDict, "Annots")
+		} else {
+			pageDict["Annots"] = kept
+		}
+	}
+
+	return nil
+}
+
+// removeJavaScript drops the document JavaScript name tree and every reference to a
+// JavaScript action, whether an action (A), an open action or an additional action (AA).
+func removeJavaScript(pdfCtx *model.Context) error {
+	names, err := pdfCtx.DereferenceDict(pdfCtx.RootDict["Names"])
+	if err != nil {
+		return err
+	}
+	if names != nil {
+		delete(names, "JavaScript")
+	}
+	delete(pdfCtx.Names, "JavaScript")
+
+	isJavaScript := func(o types.Object) bool {
+		d, err := pdfCtx.DereferenceDict(o)
+		if err != nil || d == nil {
+			return false
+		}
+		s := d.NameEntry("S")
+		return s != nil && *s == "JavaScript"
+	}
+
+	var strip func(o types.Object)
+	strip = func(o types.Object) {
+		switch o := o.(type) {
+		case types.Dict:
+			for _, key := range []string{"A", "OpenAction", "Next"} {
+				if v, found := o[key]; found && isJavaScript(v) {
+					delete(o, key)
+				}
+			}
+			if aa, err := pdfCtx.DereferenceDict(o["AA"]); err == nil && aa != nil {
+				for trigger, action := range aa {
+					if isJavaScript(action) {
+						delete(aa, trigger)
+					}
+				}
+				if len(aa) == 0 {
+					delete(o, "AA")
+				}
+			}
+			for _, v := range o {
+				strip(v)
+			}
+		case types.StreamDict:
+			strip(o.Dict)
+		case types.Array:
+			for _, v := range o {
+				strip(v)
+			}
+		}
+	}
+
+	for _, entry := range pdfCtx.Table {
+		if entry != nil && !entry.Free && entry.Object != nil {
+			strip(entry.Object)
+		}
+	}
+	strip(pdfCtx.RootDict)
+
+	return nil
 }
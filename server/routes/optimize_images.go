@@ -0,0 +1,417 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"math"
+
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/image/draw"
+)
+
+const (
+	// Images are only downsampled when they exceed the target resolution by this factor
+	downsampleThreshold = 1.1
+	// JPEG quality of downsampled JPEG images when no quality is given
+	defaultJPEGQuality = 90
+	// Nesting depth of form XObjects followed when measuring images
+	maxFormDepth = 12
+)
+
+// compressImages re-encodes the images of pdfCtx. With dpi set, images drawn at a higher
+// resolution are scaled down to dpi. With quality set, images other than soft masks are
+// encoded as JPEG of that quality. Images only change when the result is smaller, and images
+// with a color key mask or this can not decode (CMYK, indexed, bilevel, JPEG 2000, ...) are
+// left alone. It stops with the error of ctx once ctx is done.
+func compressImages(ctx context.Context, pdfCtx *model.Context, dpi, quality int) error {
+	var (
+		sizes map[int][2]float64
+		err   error
+	)
+	if dpi > 0 {
+		if sizes, err = imageDisplaySizes(pdfCtx); err != nil {
+			return err
+		}
+	}
+
+	masks := softMasks(pdfCtx)
+
+	for objNr, entry := range pdfCtx.Table {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry == nil || entry.Free {
+			continue
+		}
+		sd, ok := entry.Object.(types.StreamDict)
+		if !ok {
+			continue
+		}
+		if subtype := sd.Dict.Subtype(); subtype == nil || *subtype != "Image" {
+			continue
+		}
+
+		scale := 1.0
+		if size, found := sizes[objNr]; found {
+			scale = imageScale(sd.Dict, size, dpi)
+		}
+
+		imageQuality := quality
+		if masks[objNr] {
+			// Soft masks are alpha channels, JPEG artifacts would show as halos
+			imageQuality = 0
+		}
+
+		compressed, ok, err := compressImage(pdfCtx, sd, scale, imageQuality)
+		if err != nil {
+			log.Debug().Err(err).Int("object", objNr).Msg("image left as is")
+			continue
+		}
+		if ok {
+			entry.Object = compressed
+		}
+	}
+
+	return nil
+}
+
+// imageScale returns the factor the image sd, drawn at most at size points, is scaled by to
+// reach dpi, or 1 when it does not exceed dpi.
+func imageScale(d types.Dict, size [2]float64, dpi int) float64 {
+	w, h := d.IntEntry("Width"), d.IntEntry("Height")
+	if w == nil || h == nil || size[0] <= 0 || size[1] <= 0 {
+		return 1
+	}
+
+	resolution := math.Min(float64(*w)*72/size[0], float64(*h)*72/size[1])
+	if resolution <= float64(dpi)*downsampleThreshold {
+		return 1
+	}
+
+	return float64(dpi) / resolution
+}
+
+// softMasks returns the object numbers of the images used as soft mask of another image.
+func softMasks(pdfCtx *model.Context) map[int]bool {
+	masks := map[int]bool{}
+	for _, entry := range pdfCtx.Table {
+		if entry == nil || entry.Free {
+			continue
+		}
+		if sd, ok := entry.Object.(types.StreamDict); ok {
+			if smask, ok := sd.Dict["SMask"].(types.IndirectRef); ok {
+				masks[smask.ObjectNumber.Value()] = true
+			}
+		}
+	}
+	return masks
+}
+
+// imageDisplaySizes returns, per image object number, the largest size in points any page
+// draws the image at. Soft masks take the size of their image. Images of content that can
+// not be parsed get an infinite size so they are never downsampled.
+func imageDisplaySizes(pdfCtx *model.Context) (map[int][2]float64, error) {
+	sizes := map[int][2]float64{}
+
+	for pageNr := 1; pageNr <= pdfCtx.PageCount; pageNr++ {
+		pageDict, _, inhAttrs, err := pdfCtx.PageDict(pageNr, false)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := pdfCtx.DereferenceDict(pageDict["Resources"])
+		if err != nil {
+			return nil, err
+		}
+		if res == nil && inhAttrs != nil {
+			res = inhAttrs.Resources
+		}
+
+		content, err := pdfCtx.PageContent(pageDict)
+		if err != nil {
+			return nil, err
+		}
+
+		measureImages(pdfCtx, content, res, identityMatrix, sizes, 0)
+	}
+
+	return sizes, nil
+}
+
+// measureImages records the size of every image content draws, following form XObjects.
+func measureImages(pdfCtx *model.Context, content []byte, res types.Dict, ctm matrix, sizes map[int][2]float64, depth int) {
+	xObjects, err := pdfCtx.DereferenceDict(res["XObject"])
+	if err != nil || xObjects == nil {
+		return
+	}
+
+	record := func(objNr int, w, h float64) {
+		size := sizes[objNr]
+		sizes[objNr] = [2]float64{math.Max(size[0], w), math.Max(size[1], h)}
+	}
+
+	ops, err := parseContent(content)
+	if err != nil {
+		for _, o := range xObjects {
+			if indRef, ok := o.(types.IndirectRef); ok {
+				record(indRef.ObjectNumber.Value(), math.Inf(1), math.Inf(1))
+			}
+		}
+		return
+	}
+
+	var stack []matrix
+	for _, op := range ops {
+		switch op.Operator {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if len(stack) > 0 {
+				ctm = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if m, ok := operandMatrix(op.Operands); ok {
+				ctm = m.multiply(ctm)
+			}
+		case "Do":
+			if len(op.Operands) != 1 {
+				continue
+			}
+			name, ok := op.Operands[0].(types.Name)
+			if !ok {
+				continue
+			}
+			indRef, ok := xObjects[name.Value()].(types.IndirectRef)
+			if !ok {
+				continue
+			}
+			sd, _, err := pdfCtx.DereferenceStreamDict(indRef)
+			if err != nil || sd == nil {
+				continue
+			}
+
+			switch subtype := sd.Dict.Subtype(); {
+			case subtype == nil:
+			case *subtype == "Image":
+				w, h := ctm.scale()
+				record(indRef.ObjectNumber.Value(), w, h)
+				if smask, ok := sd.Dict["SMask"].(types.IndirectRef); ok {
+					record(smask.ObjectNumber.Value(), w, h)
+				}
+			case *subtype == "Form" && depth < maxFormDepth:
+				if err := sd.Decode(); err != nil {
+					continue
+				}
+				formRes, err := pdfCtx.DereferenceDict(sd.Dict["Resources"])
+				if err != nil {
+					continue
+				}
+				if formRes == nil {
+					formRes = res
+				}
+				m, _ := operandMatrix(sd.Dict.ArrayEntry("Matrix"))
+				measureImages(pdfCtx, sd.Content, formRes, m.multiply(ctm), sizes, depth+1)
+			}
+		}
+	}
+}
+
+// compressImage scales the image sd by scale and encodes it as JPEG of quality if set, or
+// else the way it was. It reports false if the image can not be decoded or would not get smaller.
+func compressImage(pdfCtx *model.Context, sd types.StreamDict, scale float64, quality int) (types.StreamDict, bool, error) {
+	isJPEG := len(sd.FilterPipeline) == 1 && sd.FilterPipeline[0].Name == filter.DCT
+	if scale >= 1 && quality == 0 {
+		return sd, false, nil
+	}
+
+	if imageMask := sd.Dict.BooleanEntry("ImageMask"); imageMask != nil && *imageMask {
+		return sd, false, nil
+	}
+
+	// A color key mask hides samples of exact values, which scaling and JPEG change
+	mask, err := pdfCtx.Dereference(sd.Dict["Mask"])
+	if err != nil {
+		return sd, false, err
+	}
+	if _, colorKey := mask.(types.Array); colorKey {
+		return sd, false, nil
+	}
+
+	img, err := decodeImage(pdfCtx, sd, isJPEG)
+	if err != nil || img == nil {
+		return sd, false, err
+	}
+
+	if scale < 1 {
+		b := img.Bounds()
+		w := max(1, int(math.Round(float64(b.Dx())*scale)))
+		h := max(1, int(math.Round(float64(b.Dy())*scale)))
+
+		var dst draw.Image
+		if _, gray := img.(*image.Gray); gray {
+			dst = image.NewGray(image.Rect(0, 0, w, h))
+		} else {
+			dst = image.NewRGBA(image.Rect(0, 0, w, h))
+		}
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+		img = dst
+	}
+
+	if isJPEG && quality == 0 {
+		quality = defaultJPEGQuality
+	}
+
+	d := sd.Dict.Clone().(types.Dict)
+	d["Width"] = types.Integer(img.Bounds().Dx())
+	d["Height"] = types.Integer(img.Bounds().Dy())
+	d["BitsPerComponent"] = types.Integer(8)
+	delete(d, "DecodeParms")
+
+	compressed := types.StreamDict{Dict: d, CSComponents: sd.CSComponents}
+
+	if quality > 0 {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return sd, false, err
+		}
+		d["Filter"] = types.Name(filter.DCT)
+		d["Length"] = types.Integer(buf.Len())
+		compressed.Raw = buf.Bytes()
+		compressed.FilterPipeline = []types.PDFFilter{{Name: filter.DCT}}
+		streamLength := int64(buf.Len())
+		compressed.StreamLength = &streamLength
+	} else {
+		d["Filter"] = types.Name(filter.Flate)
+		compressed.Content = imageSamples(img)
+		compressed.FilterPipeline = []types.PDFFilter{{Name: filter.Flate}}
+		if err := compressed.Encode(); err != nil {
+			return sd, false, err
+		}
+	}
+
+	if len(compressed.Raw) >= len(sd.Raw) {
+		return sd, false, nil
+	}
+
+	return compressed, true, nil
+}
+
+// decodeImage decodes the 8 bit gray or RGB image sd, nil if it is anything else.
+func decodeImage(pdfCtx *model.Context, sd types.StreamDict, isJPEG bool) (image.Image, error) {
+	if isJPEG {
+		img, err := jpeg.Decode(bytes.NewReader(sd.Raw))
+		if err != nil {
+			return nil, err
+		}
+		switch img.(type) {
+		case *image.Gray, *image.YCbCr:
+			return img, nil
+		}
+		return nil, nil
+	}
+
+	for _, f := range sd.FilterPipeline {
+		switch f.Name {
+		case filter.Flate, filter.LZW, filter.ASCII85, filter.ASCIIHex, filter.RunLength:
+		default:
+			return nil, nil
+		}
+	}
+
+	w, h, bpc := sd.Dict.IntEntry("Width"), sd.Dict.IntEntry("Height"), sd.Dict.IntEntry("BitsPerComponent")
+	if w == nil || h == nil || bpc == nil || *bpc != 8 || *w <= 0 || *h <= 0 {
+		return nil, nil
+	}
+
+	components, err := imageComponents(pdfCtx, sd.Dict["ColorSpace"])
+	if err != nil || (components != 1 && components != 3) {
+		return nil, err
+	}
+
+	if err := sd.Decode(); err != nil {
+		return nil, err
+	}
+	if len(sd.Content) < *w**h*components {
+		return nil, nil
+	}
+
+	rect := image.Rect(0, 0, *w, *h)
+	if components == 1 {
+		return &image.Gray{Pix: sd.Content[:*w**h], Stride: *w, Rect: rect}, nil
+	}
+
+	img := image.NewRGBA(rect)
+	for i, j := 0, 0; i < *w**h*3; i, j = i+3, j+4 {
+		copy(img.Pix[j:j+3], sd.Content[i:i+3])
+		img.Pix[j+3] = 0xFF
+	}
+	return img, nil
+}
+
+// imageComponents returns the number of components of a gray or RGB color space, 0 for others.
+func imageComponents(pdfCtx *model.Context, o types.Object) (int, error) {
+	cs, err := pdfCtx.Dereference(o)
+	if err != nil {
+		return 0, err
+	}
+
+	switch cs := cs.(type) {
+	case types.Name:
+		switch cs {
+		case "DeviceGray", "CalGray":
+			return 1, nil
+		case "DeviceRGB", "CalRGB":
+			return 3, nil
+		}
+	case types.Array:
+		if len(cs) < 2 {
+			return 0, nil
+		}
+		switch cs[0] {
+		case types.Name("CalGray"):
+			return 1, nil
+		case types.Name("CalRGB"):
+			return 3, nil
+		case types.Name("ICCBased"):
+			icc, _, err := pdfCtx.DereferenceStreamDict(cs[1])
+			if err != nil || icc == nil {
+				return 0, err
+			}
+			if n := icc.Dict.IntEntry("N"); n != nil && (*n == 1 || *n == 3) {
+				return *n, nil
+			}
+		}
+	}
+
+	return 0, nil
+}
+
+// imageSamples returns the 8 bit samples of a gray or RGBA image, dropping alpha.
+func imageSamples(img image.Image) []byte {
+	switch img := img.(type) {
+	case *image.Gray:
+		return img.Pix
+	case *image.RGBA:
+		samples := make([]byte, 0, len(img.Pix)/4*3)
+		for i := 0; i < len(img.Pix); i += 4 {
+			samples = append(samples, img.Pix[i:i+3]...)
+		}
+		return samples
+	}
+
+	b := img.Bounds()
+	samples := make([]byte, 0, b.Dx()*b.Dy()*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			samples = append(samples, byte(r>>8), byte(g>>8), byte(bl>>8))
+		}
+	}
+	return samples
+}
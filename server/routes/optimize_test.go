@@ -0,0 +1,243 @@
+package routes
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"maps"
+	"pdftool/internal/pdftest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// grayPixel is a 1 by 1 image.
+const grayPixel = "/Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8"
+
+func TestRemoveUnusedResources(t *testing.T) {
+	var b pdftest.Builder
+	helvetica := b.Add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	courier := b.Add("<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>")
+
+	glyphImage := b.Add(pdftest.Stream(grayPixel, "A"))
+	glyph := b.Add(pdftest.Stream("", "1000 0 d0 q 1000 0 0 1000 0 0 cm /Im3 Do Q"))
+	type3 := b.Add(fmt.Sprintf("<< /Type /Font /Subtype /Type3 /FontBBox [0 0 1000 1000] /FontMatrix [0.001 0 0 0.001 0 0] "+
+		"/CharProcs << /a %d 0 R >> /Encoding << /Type /Encoding /Differences [97 /a] >> /FirstChar 97 /LastChar 97 "+
+		"/Widths [1000] /Resources << /XObject << /Im3 %d 0 R >> >> >>", glyph, glyphImage))
+
+	patternImage := b.Add(pdftest.Stream(grayPixel, "A"))
+	pattern := b.Add(pdftest.Stream(fmt.Sprintf("/PatternType 1 /PaintType 1 /TilingType 1 /BBox [0 0 10 10] /XStep 10 /YStep 10 "+
+		"/Resources << /XObject << /Im2 %d 0 R >> >>", patternImage), "q 10 0 0 10 0 0 cm /Im2 Do Q"))
+
+	unusedImage := b.Add(pdftest.Stream(grayPixel, "A"))
+	form := b.Add(pdftest.Stream(fmt.Sprintf("/Type /XObject /Subtype /Form /BBox [0 0 100 100] "+
+		"/Resources << /Pattern << /P1 %d 0 R >> /XObject << /Im1 %d 0 R >> >>", pattern, unusedImage),
+		"/Pattern cs /P1 scn 0 0 100 100 re f"))
+
+	maskImage, unusedMaskImage := b.Add(pdftest.Stream(grayPixel, "A")), b.Add(pdftest.Stream(grayPixel, "A"))
+	group := b.Add(pdftest.Stream(fmt.Sprintf("/Type /XObject /Subtype /Form /BBox [0 0 100 100] /Group << /S /Transparency >> "+
+		"/Resources << /XObject << /Im4 %d 0 R /Im5 %d 0 R >> >>", maskImage, unusedMaskImage), "/Im4 Do"))
+
+	appearance := b.Add(pdftest.Stream(fmt.Sprintf("/Type /XObject /Subtype /Form /BBox [0 0 100 20] "+
+		"/Resources << /Font << /F4 %d 0 R /F5 %d 0 R >> >>", helvetica, courier), "BT /F4 10 Tf (x) Tj ET"))
+	annot := b.Add(fmt.Sprintf("<< /Type /Annot /Subtype /Widget /Rect [0 0 100 20] /AP << /N << /On %d 0 R /Off %d 0 R >> >> >>",
+		appearance, b.Add(pdftest.Stream("/Type /XObject /Subtype /Form /BBox [0 0 100 20]", ""))))
+
+	// Not a content stream, the name in it uses nothing
+	script := b.Add(pdftest.Stream("", "app.alert(/F5/.source)"))
+
+	content := b.Add(pdftest.Stream("", "/GS1 gs BT /F1 12 Tf (a) Tj /F3 12 Tf (a) Tj ET /Fm1 Do"))
+	root := b.Document(fmt.Sprintf("/MediaBox [0 0 200 200] /Contents %d 0 R /Annots [%d 0 R] /Resources << "+
+		"/Font << /F1 %d 0 R /F2 %d 0 R /F3 %d 0 R >> /XObject << /Fm1 %d 0 R >> "+
+		"/ExtGState << /GS1 << /SMask << /S /Luminosity /G %d 0 R >> >> /GS9 << /CA 0.5 >> >> >>",
+		content, annot, helvetica, courier, type3, form, group))
+	b.Set(root, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R /OpenAction << /S /JavaScript /JS %d 0 R >> >>", root-2, script))
+
+	pdfCtx, err := api.ReadContextFile(b.Write(t, root))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := removeUnusedResources(context.Background(), pdfCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	pageDict, _, _, err := pdfCtx.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		d    types.Dict
+		want string
+	}{
+		{"page", pageDict, "ExtGState: GS1, Font: F1 F3, XObject: Fm1"},
+		{"form", testObjectDict(t, pdfCtx, form), "Pattern: P1"},
+		{"pattern", testObjectDict(t, pdfCtx, pattern), "XObject: Im2"},
+		{"type 3 font", testObjectDict(t, pdfCtx, type3), "XObject: Im3"},
+		{"soft mask group", testObjectDict(t, pdfCtx, group), "XObject: Im4"},
+		{"appearance", testObjectDict(t, pdfCtx, appearance), "Font: F4"},
+	}
+
+	for _, tt := range tests {
+		res, err := pdfCtx.DereferenceDict(tt.d["Resources"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, category := range slices.Sorted(maps.Keys(res)) {
+			names, err := pdfCtx.DereferenceDict(res[category])
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, category+": "+strings.Join(slices.Sorted(maps.Keys(names)), " "))
+		}
+		if strings.Join(got, ", ") != tt.want {
+			t.Errorf("%s: got resources %q, want %q", tt.name, strings.Join(got, ", "), tt.want)
+		}
+	}
+}
+
+func TestCompressImage(t *testing.T) {
+	gradient := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := range 64 {
+		for x := range 64 {
+			gradient.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), 128, 0xFF})
+		}
+	}
+	var jpegData bytes.Buffer
+	if err := jpeg.Encode(&jpegData, gradient, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	flateData := testDeflate(t, imageSamples(gradient))
+
+	var b pdftest.Builder
+	rgb := "/Type /XObject /Subtype /Image /Width 64 /Height 64 /ColorSpace /DeviceRGB /BitsPerComponent 8"
+	flateImage := b.Add(pdftest.Stream(rgb+" /Filter /FlateDecode", flateData))
+	jpegImage := b.Add(pdftest.Stream(rgb+" /Filter /DCTDecode", jpegData.String()))
+	colorKeyImage := b.Add(pdftest.Stream(rgb+" /Filter /FlateDecode /Mask [0 10 0 10 0 10]", flateData))
+	stencilMask := b.Add(pdftest.Stream("/Type /XObject /Subtype /Image /Width 64 /Height 64 /ImageMask true /BitsPerComponent 1",
+		strings.Repeat("\x00", 8*64)))
+	root := b.Document("/MediaBox [0 0 200 200]")
+
+	pdfCtx, err := api.ReadContextFile(b.Write(t, root))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		objNr      int
+		scale      float64
+		quality    int
+		wantOK     bool
+		wantWidth  int
+		wantFilter string
+	}{
+		{"scaled down", flateImage, 0.5, 0, true, 32, filter.Flate},
+		{"scaled down JPEG", jpegImage, 0.5, 0, true, 32, filter.DCT},
+		{"re-encoded as JPEG", flateImage, 1, 50, true, 64, filter.DCT},
+		{"unchanged", flateImage, 1, 0, false, 0, ""},
+		{"color key mask", colorKeyImage, 0.5, 50, false, 0, ""},
+		{"stencil mask", stencilMask, 0.5, 0, false, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sd := testObjectStream(t, pdfCtx, tt.objNr)
+			compressed, ok, err := compressImage(pdfCtx, sd, tt.scale, tt.quality)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("got ok %t, want %t", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+
+			if width := compressed.Dict.IntEntry("Width"); width == nil || *width != tt.wantWidth {
+				t.Errorf("got width %v, want %d", width, tt.wantWidth)
+			}
+			if f := compressed.Dict.NameEntry("Filter"); f == nil || *f != tt.wantFilter {
+				t.Errorf("got filter %v, want %s", f, tt.wantFilter)
+			}
+			if len(compressed.Raw) >= len(sd.Raw) {
+				t.Errorf("got %d bytes, not less than %d", len(compressed.Raw), len(sd.Raw))
+			}
+		})
+	}
+}
+
+func TestCompressImagesInForm(t *testing.T) {
+	samples := make([]byte, 64*64)
+	for i := range samples {
+		samples[i] = byte(i % 64 * 4)
+	}
+
+	var b pdftest.Builder
+	img := b.Add(pdftest.Stream("/Type /XObject /Subtype /Image /Width 64 /Height 64 /ColorSpace /DeviceGray /BitsPerComponent 8 "+
+		"/Filter /FlateDecode", testDeflate(t, samples)))
+	// The form halves the 50 point image, drawing it at 25 points or 184 dpi
+	form := b.Add(pdftest.Stream(fmt.Sprintf("/Type /XObject /Subtype /Form /BBox [0 0 100 100] /Matrix [0.5 0 0 0.5 0 0] "+
+		"/Resources << /XObject << /Im1 %d 0 R >> >>", img), "q 50 0 0 50 0 0 cm /Im1 Do Q"))
+	content := b.Add(pdftest.Stream("", "/Fm1 Do"))
+	root := b.Document(fmt.Sprintf("/MediaBox [0 0 200 200] /Contents %d 0 R /Resources << /XObject << /Fm1 %d 0 R >> >>", content, form))
+
+	pdfCtx, err := api.ReadContextFile(b.Write(t, root))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := compressImages(context.Background(), pdfCtx, 72, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if width := testObjectStream(t, pdfCtx, img).Dict.IntEntry("Width"); width == nil || *width != 25 {
+		t.Errorf("got width %v, want 25", width)
+	}
+}
+
+func testDeflate(t *testing.T, data []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func testObjectDict(t *testing.T, pdfCtx *model.Context, objNr int) types.Dict {
+	t.Helper()
+	switch o := pdfCtx.Table[objNr].Object.(type) {
+	case types.Dict:
+		return o
+	case types.StreamDict:
+		return o.Dict
+	}
+	t.Fatalf("object %d is not a dict", objNr)
+	return nil
+}
+
+func testObjectStream(t *testing.T, pdfCtx *model.Context, objNr int) types.StreamDict {
+	t.Helper()
+	sd, ok := pdfCtx.Table[objNr].Object.(types.StreamDict)
+	if !ok {
+		t.Fatalf("object %d is not a stream", objNr)
+	}
+	return sd
+}
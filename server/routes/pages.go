@@ -0,0 +1,327 @@
+package routes
+
+import (
+	"fmt"
+	"pdftool/server/helper"
+	"slices"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/rs/zerolog/log"
+)
+
+type pagesOptions struct {
+	Operation string `json:"operation" form:"operation"`
+	Pages     string `json:"pages" form:"pages"`
+}
+
+// @Summary Extract, remove or reorder pages
+// @Description Builds a new PDF from a page selection. "extract" keeps the selected pages, "remove" drops them.
+// @Description "reorder" moves the pages listed, each at most once, to the front in the order given, the other pages
+// @Description follow in their order. "duplicate" keeps every page and inserts a copy after each selected page, one
+// @Description copy per time the page is listed.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce octet-stream
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file to process"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Param request body object false "JSON request with base64 PDF"
+// @Param operation formData string true "extract, remove, reorder or duplicate"
+// @Param pages formData string true "Page selection, e.g. 1-3,5,even,!4,l"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/pages [post]
+func Pages(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "pages",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	var opts pagesOptions
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid page options")
+	}
+
+	if stepErr := pagesPDF(result.InputPath, result.OutputPath, result.Password, opts); stepErr != nil {
+		return helper.SendErrorResponse(ctx, stepErr.code, stepErr.message)
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// pagesPDF writes the pages of inFile, opened with password, selected by opts to outFile.
+// The page tree is rearranged in place, so the document keeps its properties, outlines
+// and encryption.
+func pagesPDF(inFile, outFile, password string, opts pagesOptions) *stepError {
+	selectedPages, selErr := api.ParsePageSelection(opts.Pages)
+	if selErr != nil || len(selectedPages) == 0 {
+		return newStepError(fiber.StatusBadRequest, "Invalid page selection")
+	}
+
+	switch opts.Operation {
+	case "extract", "remove", "reorder", "duplicate":
+	default:
+		return newStepError(fiber.StatusBadRequest, "Invalid operation. Use extract, remove, reorder or duplicate")
+	}
+
+	pdfCtx, err := readFormContext(inFile, password, model.COLLECT)
+	if err != nil {
+		log.Error().Err(err).Caller().Send()
+		return newStepError(fiber.StatusBadRequest, "File is invalid, corrupted or the password is wrong.")
+	}
+
+	pageNrs, stepErr := arrangedPageNrs(opts.Operation, pdfCtx.PageCount, selectedPages)
+	if stepErr != nil {
+		return stepErr
+	}
+
+	if err := arrangePages(pdfCtx, pageNrs); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return newStepError(fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(err))
+	}
+
+	if err := api.WriteContextFile(pdfCtx, outFile); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return newStepError(fiber.StatusInternalServerError, helper.TransformPDFCPUErrorToResponse(err))
+	}
+
+	return nil
+}
+
+// arrangedPageNrs returns the numbers of the pages operation leaves of pageCount pages,
+// in their new order.
+func arrangedPageNrs(operation string, pageCount int, selectedPages []string) ([]int, *stepError) {
+	noMatch := newStepError(fiber.StatusBadRequest, "Page selection does not match any page")
+
+	if operation == "reorder" || operation == "duplicate" {
+		listed, err := api.PagesForPageCollection(pageCount, selectedPages)
+		if err != nil || len(listed) == 0 {
+			return nil, noMatch
+		}
+		count := map[int]int{}
+		for _, pageNr := range listed {
+			count[pageNr]++
+		}
+
+		pageNrs := make([]int, 0, pageCount+len(listed))
+		if operation == "duplicate" {
+			for pageNr := 1; pageNr <= pageCount; pageNr++ {
+				for range count[pageNr] + 1 {
+					pageNrs = append(pageNrs, pageNr)
+				}
+			}
+			return pageNrs, nil
+		}
+
+		for _, pageNr := range listed {
+			if count[pageNr] > 1 {
+				return nil, newStepError(fiber.StatusBadRequest, fmt.Sprintf("Page %d is listed more than once, use duplicate to copy pages", pageNr))
+			}
+		}
+		pageNrs = append(pageNrs, listed...)
+		for pageNr := 1; pageNr <= pageCount; pageNr++ {
+			if count[pageNr] == 0 {
+				pageNrs = append(pageNrs, pageNr)
+			}
+		}
+		return pageNrs, nil
+	}
+
+	selected, err := api.PagesForPageSelection(pageCount, selectedPages, true, true)
+	if err != nil || len(selected) == 0 {
+		return nil, noMatch
+	}
+
+	var pageNrs []int
+	for pageNr := 1; pageNr <= pageCount; pageNr++ {
+		if selected[pageNr] == (operation == "extract") {
+			pageNrs = append(pageNrs, pageNr)
+		}
+	}
+	if len(pageNrs) == 0 {
+		return nil, newStepError(fiber.StatusBadRequest, "Removing every page leaves an empty document")
+	}
+
+	return pageNrs, nil
+}
+
+// arrangePages makes the pages of pdfCtx those numbered pageNrs, in that order, under
+// the root of the page tree. Pages listed more than once are copied, with their own
+// copies of their annotations. Attributes pages inherited are set on the pages.
+func arrangePages(pdfCtx *model.Context, pageNrs []int) error {
+	pagesRef := pdfCtx.RootDict.IndirectRefEntry("Pages")
+	if pagesRef == nil {
+		return fmt.Errorf("missing page tree")
+	}
+	pagesDict, err := pdfCtx.DereferenceDict(*pagesRef)
+	if err != nil {
+		return err
+	}
+
+	if err := pruneFormFields(pdfCtx, pageNrs); err != nil {
+		return err
+	}
+
+	kids := make(types.Array, 0, len(pageNrs))
+	placed := map[int]bool{}
+	for _, pageNr := range pageNrs {
+		pageDict, pageRef, inhAttrs, err := pdfCtx.PageDict(pageNr, false)
+		if err != nil {
+			return err
+		}
+		if pageDict == nil || pageRef == nil {
+			return fmt.Errorf("page %d not found", pageNr)
+		}
+
+		if _, found := pageDict["Resources"]; !found && inhAttrs.Resources != nil {
+			pageDict["Resources"] = inhAttrs.Resources
+		}
+		if _, found := pageDict["MediaBox"]; !found && inhAttrs.MediaBox != nil {
+			pageDict["MediaBox"] = inhAttrs.MediaBox.Array()
+		}
+		if _, found := pageDict["CropBox"]; !found && inhAttrs.CropBox != nil {
+			pageDict["CropBox"] = inhAttrs.CropBox.Array()
+		}
+		if _, found := pageDict["Rotate"]; !found && inhAttrs.Rotate != 0 {
+			pageDict["Rotate"] = types.Integer(inhAttrs.Rotate)
+		}
+		pageDict["Parent"] = *pagesRef
+
+		if placed[pageNr] {
+			if pageRef, err = copyPage(pdfCtx, pageDict); err != nil {
+				return err
+			}
+		}
+		placed[pageNr] = true
+		kids = append(kids, *pageRef)
+	}
+
+	// Pages left out are no longer referenced from the page tree and not written,
+	// unless something else refers to them. The other entries of the root stay, the
+	// attributes it passes on apply to the pages that already inherited them.
+	pagesDict["Kids"] = kids
+	pagesDict["Count"] = types.Integer(len(kids))
+	pdfCtx.PageCount = len(kids)
+
+	return nil
+}
+
+// pruneFormFields drops the widgets on the pages of pdfCtx not in pageNrs from its form,
+// and the fields left without widgets, so the form does not keep those pages.
+func pruneFormFields(pdfCtx *model.Context, pageNrs []int) error {
+	form, err := pdfCtx.DereferenceDict(pdfCtx.RootDict["AcroForm"])
+	if err != nil || form == nil {
+		return err
+	}
+	fields, err := pdfCtx.DereferenceArray(form["Fields"])
+	if err != nil || fields == nil {
+		return err
+	}
+
+	removed := map[int]bool{}
+	for pageNr := 1; pageNr <= pdfCtx.PageCount; pageNr++ {
+		if slices.Contains(pageNrs, pageNr) {
+			continue
+		}
+		pageDict, _, _, err := pdfCtx.PageDict(pageNr, false)
+		if err != nil {
+			return err
+		}
+		annots, err := pdfCtx.DereferenceArray(pageDict["Annots"])
+		if err != nil {
+			return err
+		}
+		for _, o := range annots {
+			if ref, ok := o.(types.IndirectRef); ok {
+				removed[ref.ObjectNumber.Value()] = true
+			}
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	fields, err = pruneFields(pdfCtx, fields, removed)
+	if err != nil {
+		return err
+	}
+	form["Fields"] = fields
+
+	return nil
+}
+
+// pruneFields returns fields without the widgets numbered in removed and without the
+// fields whose widgets are all removed, removing them from the kids of fields too.
+func pruneFields(pdfCtx *model.Context, fields types.Array, removed map[int]bool) (types.Array, error) {
+	kept := make(types.Array, 0, len(fields))
+	for _, o := range fields {
+		if ref, ok := o.(types.IndirectRef); ok && removed[ref.ObjectNumber.Value()] {
+			continue
+		}
+		field, err := pdfCtx.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+		if field != nil && field["Kids"] != nil {
+			kids, err := pdfCtx.DereferenceArray(field["Kids"])
+			if err != nil {
+				return nil, err
+			}
+			if kids, err = pruneFields(pdfCtx, kids, removed); err != nil {
+				return nil, err
+			}
+			if len(kids) == 0 {
+				continue
+			}
+			field["Kids"] = kids
+		}
+		kept = append(kept, o)
+	}
+	return kept, nil
+}
+
+// copyPage adds a copy of pageDict sharing its content and resources. Its annotations
+// are copied too, as an annotation belongs to a single page, widgets leaving their form.
+func copyPage(pdfCtx *model.Context, pageDict types.Dict) (*types.IndirectRef, error) {
+	pageCopy := pageDict.Clone().(types.Dict)
+	pageRef, err := pdfCtx.IndRefForNewObject(pageCopy)
+	if err != nil {
+		return nil, err
+	}
+
+	annots, err := pdfCtx.DereferenceArray(pageDict["Annots"])
+	if err != nil || annots == nil {
+		delete(pageCopy, "Annots")
+		return pageRef, nil
+	}
+
+	annotsCopy := make(types.Array, 0, len(annots))
+	for _, o := range annots {
+		annot, err := pdfCtx.DereferenceDict(o)
+		if err != nil || annot == nil {
+			continue
+		}
+		annotCopy := annot.Clone().(types.Dict)
+		annotCopy["P"] = *pageRef
+		delete(annotCopy, "Parent")
+		delete(annotCopy, "Popup")
+		delete(annotCopy, "IRT")
+		annotRef, err := pdfCtx.IndRefForNewObject(annotCopy)
+		if err != nil {
+			return nil, err
+		}
+		annotsCopy = append(annotsCopy, *annotRef)
+	}
+	pageCopy["Annots"] = annotsCopy
+
+	return pageRef, nil
+}
@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"fmt"
+	"path/filepath"
+	"pdftool/internal/pdftest"
+	"slices"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+func TestArrangedPageNrs(t *testing.T) {
+	tests := []struct {
+		operation string
+		pages     string
+		want      []int
+		wantErr   bool
+	}{
+		{operation: "extract", pages: "2-3", want: []int{2, 3}},
+		{operation: "remove", pages: "2-3", want: []int{1, 4, 5}},
+		{operation: "remove", pages: "1-5", wantErr: true},
+		{operation: "reorder", pages: "5,1-3", want: []int{5, 1, 2, 3, 4}},
+		{operation: "reorder", pages: "3,2", want: []int{3, 2, 1, 4, 5}},
+		{operation: "reorder", pages: "2,2", wantErr: true},
+		{operation: "duplicate", pages: "2", want: []int{1, 2, 2, 3, 4, 5}},
+		{operation: "duplicate", pages: "4,2,4", want: []int{1, 2, 2, 3, 4, 4, 4, 5}},
+		{operation: "duplicate", pages: "9", wantErr: true},
+	}
+	for _, tt := range tests {
+		selectedPages, err := api.ParsePageSelection(tt.pages)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, stepErr := arrangedPageNrs(tt.operation, 5, selectedPages)
+		if (stepErr != nil) != tt.wantErr {
+			t.Errorf("%s %s: got error %v, want error %v", tt.operation, tt.pages, stepErr, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s %s: got pages %v, want %v", tt.operation, tt.pages, got, tt.want)
+		}
+	}
+}
+
+func TestPagesPDFPrunesForm(t *testing.T) {
+	var b pdftest.Builder
+	font := b.Add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	first, second, group, groupFirst, groupSecond := b.Reserve(), b.Reserve(), b.Reserve(), b.Reserve(), b.Reserve()
+	root := b.Document(
+		fmt.Sprintf("/MediaBox [0 0 200 200] /Annots [%d 0 R %d 0 R]", first, groupFirst),
+		fmt.Sprintf("/MediaBox [0 0 200 200] /Annots [%d 0 R %d 0 R]", second, groupSecond),
+	)
+	tree, page1, page2 := root-3, root-2, root-1
+
+	widget := "<< /Type /Annot /Subtype /Widget /Rect [10 %d 190 %d] /P %d 0 R %s >>"
+	b.Set(first, fmt.Sprintf(widget, 10, 30, page1, "/FT /Tx /T (first)"))
+	b.Set(second, fmt.Sprintf(widget, 10, 30, page2, "/FT /Tx /T (second)"))
+	b.Set(group, fmt.Sprintf("<< /FT /Tx /T (group) /Kids [%d 0 R %d 0 R] >>", groupFirst, groupSecond))
+	b.Set(groupFirst, fmt.Sprintf(widget, 50, 70, page1, fmt.Sprintf("/Parent %d 0 R", group)))
+	b.Set(groupSecond, fmt.Sprintf(widget, 50, 70, page2, fmt.Sprintf("/Parent %d 0 R", group)))
+	b.Set(tree, fmt.Sprintf("<< /Type /Pages /Kids [%d 0 R %d 0 R] /Count 2 /Rotate 90 >>", page1, page2))
+	b.Set(root, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R /AcroForm << /Fields [%d 0 R %d 0 R %d 0 R] /DA (/Helv 0 Tf 0 g) "+
+		"/DR << /Font << /Helv %d 0 R >> >> >> >>", tree, first, second, group, font))
+
+	outFile := filepath.Join(t.TempDir(), "out.pdf")
+	if stepErr := pagesPDF(b.Write(t, root), outFile, "", pagesOptions{Operation: "remove", Pages: "2"}); stepErr != nil {
+		t.Fatal(stepErr.message)
+	}
+
+	pdfCtx, err := api.ReadContextFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pdfCtx.PageCount != 1 {
+		t.Fatalf("got %d pages, want 1", pdfCtx.PageCount)
+	}
+	pagesDict, err := pdfCtx.DereferenceDict(pdfCtx.RootDict["Pages"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotate := pagesDict.IntEntry("Rotate"); rotate == nil || *rotate != 90 {
+		t.Errorf("got root Rotate %v, want 90", rotate)
+	}
+
+	form, err := pdfCtx.DereferenceDict(pdfCtx.RootDict["AcroForm"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := pdfCtx.DereferenceArray(form["Fields"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, o := range fields {
+		field, err := pdfCtx.DereferenceDict(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := ""
+		if s := field.StringEntry("T"); s != nil {
+			name = *s
+		}
+		if name == "group" {
+			if kids := field.ArrayEntry("Kids"); len(kids) != 1 {
+				t.Errorf("group has %d widgets, want 1", len(kids))
+			}
+		}
+		names = append(names, name)
+	}
+	if want := []string{"first", "group"}; !slices.Equal(names, want) {
+		t.Errorf("got fields %v, want %v", names, want)
+	}
+}
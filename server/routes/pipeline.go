@@ -0,0 +1,303 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"pdftool/server/helper"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v3"
+)
+
+// A pipeline runs at most this many steps.
+const maxPipelineSteps = 20
+
+// stepError is a failed operation, with the status code to respond with.
+type stepError struct {
+	code    int
+	message string
+}
+
+func newStepError(code int, message string) *stepError {
+	return &stepError{
+		code:    code,
+		message: message,
+	}
+}
+
+// pipelineStep runs an operation on inFile, opened with password, with the options
+// decoded from the JSON object options, and writes outFile. It returns the password
+// that opens outFile. Long operations stop once ctx is done.
+type pipelineStep func(ctx context.Context, inFile, outFile, password string, options []byte) (string, *stepError)
+
+// pipelineSteps are the operations a pipeline can chain, those turning a PDF into
+// another, by the names of their routes.
+var pipelineSteps = map[string]pipelineStep{
+	"repair": func(_ context.Context, inFile, outFile, password string, _ []byte) (string, *stepError) {
+		// Like the repair route, files that need no repair are left as they are
+		if !needsRepair(inFile, password) {
+			return password, copyStepFile(inFile, outFile)
+		}
+		return password, repairPDF(inFile, outFile, password)
+	},
+	"optimize": func(ctx context.Context, inFile, outFile, password string, options []byte) (string, *stepError) {
+		var opts optimizeOptions
+		if err := decodeStepOptions(options, &opts); err != nil {
+			return password, err
+		}
+		return password, optimizePDF(ctx, inFile, outFile, password, opts)
+	},
+	"watermark": func(_ context.Context, inFile, outFile, password string, options []byte) (string, *stepError) {
+		var opts watermarkOptions
+		if err := decodeStepOptions(options, &opts); err != nil {
+			return password, err
+		}
+		image := func() (io.ReadCloser, error) { return decodeWatermarkImage(opts.Image) }
+		return password, watermarkPDF(inFile, outFile, password, opts, image)
+	},
+	"rotate": func(_ context.Context, inFile, outFile, password string, options []byte) (string, *stepError) {
+		var opts rotateOptions
+		if err := decodeStepOptions(options, &opts); err != nil {
+			return password, err
+		}
+		return password, rotatePDF(inFile, outFile, password, opts)
+	},
+	"pages": func(_ context.Context, inFile, outFile, password string, options []byte) (string, *stepError) {
+		var opts pagesOptions
+		if err := decodeStepOptions(options, &opts); err != nil {
+			return password, err
+		}
+		return password, pagesPDF(inFile, outFile, password, opts)
+	},
+	"metadata": func(_ context.Context, inFile, outFile, password string, options []byte) (string, *stepError) {
+		var opts metadataOptions
+		if err := decodeStepOptions(options, &opts); err != nil {
+			return password, err
+		}
+		return password, metadataPDF(inFile, outFile, password, opts)
+	},
+	"form/fill": func(_ context.Context, inFile, outFile, password string, options []byte) (string, *stepError) {
+		var opts formFillOptions
+		if err := decodeStepOptions(options, &opts); err != nil {
+			return password, err
+		}
+		return password, formFillPDF(inFile, outFile, password, opts)
+	},
+	"encrypt": func(_ context.Context, inFile, outFile, password string, options []byte) (string, *stepError) {
+		// The document is encrypted with a password of its own, replacing the
+		// encryption of an encrypted input
+		var opts struct {
+			encryptOptions
+			Password string `json:"password"`
+		}
+		if err := decodeStepOptions(options, &opts); err != nil {
+			return password, err
+		}
+		if opts.Password == "" {
+			return password, newStepError(fiber.StatusBadRequest, "Password is required")
+		}
+		return opts.Password, encryptPDF(inFile, outFile, password, opts.Password, opts.encryptOptions)
+	},
+	"decrypt": func(_ context.Context, inFile, outFile, password string, _ []byte) (string, *stepError) {
+		if password == "" {
+			return password, newStepError(fiber.StatusBadRequest, "Password is required")
+		}
+		return "", decryptPDF(inFile, outFile, password)
+	},
+	"reencrypt": func(_ context.Context, inFile, outFile, password string, options []byte) (string, *stepError) {
+		var opts reencryptOptions
+		if err := decodeStepOptions(options, &opts); err != nil {
+			return password, err
+		}
+		if password == "" {
+			return password, newStepError(fiber.StatusBadRequest, "Password is required")
+		}
+		if err := reencryptPDF(inFile, outFile, password, opts); err != nil {
+			return password, err
+		}
+		if opts.NewUserPassword != nil {
+			return *opts.NewUserPassword, nil
+		}
+		return password, nil
+	},
+}
+
+// copyStepFile copies inFile to outFile, for steps with nothing to change.
+func copyStepFile(inFile, outFile string) *stepError {
+	in, err := os.Open(inFile)
+	if err != nil {
+		return newStepError(fiber.StatusInternalServerError, "Failed to read file")
+	}
+	defer in.Close()
+
+	out, err := os.Create(outFile)
+	if err != nil {
+		return newStepError(fiber.StatusInternalServerError, "Failed to write file")
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return newStepError(fiber.StatusInternalServerError, "Failed to write file")
+	}
+	if err := out.Close(); err != nil {
+		return newStepError(fiber.StatusInternalServerError, "Failed to write file")
+	}
+	return nil
+}
+
+// decodeStepOptions decodes the JSON object options into opts, leaving it as it is if
+// the step has no options.
+func decodeStepOptions(options []byte, opts any) *stepError {
+	if len(options) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(options, opts); err != nil {
+		return newStepError(fiber.StatusBadRequest, "Invalid options")
+	}
+	return nil
+}
+
+// pipelineRequestStep is a step of a pipeline request, the name of an operation and
+// its options, which are those of the operation's route.
+type pipelineRequestStep struct {
+	Operation string          `json:"operation"`
+	Options   json.RawMessage `json:"options"`
+}
+
+// @Summary Run several operations on a PDF file in a row
+// @Description Runs steps on a PDF file one after the other, each on the output of the one before, and returns the
+// @Description output of the last. The file stays on the server in between. Steps are given as a JSON array of
+// @Description operation names or of {"operation": ..., "options": {...}} objects, whose options are those of the
+// @Description operation's route, or in a form as a comma separated list of names. The operations are repair, optimize,
+// @Description watermark, rotate, pages, metadata, form/fill, encrypt, decrypt and reencrypt. pdf_password opens the
+// @Description input, encrypt takes the new password as its password option, replacing any encryption of the input,
+// @Description and later steps open the file with it.
+// @Description Like the repair route, the repair step leaves files that need no repair as they are.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce octet-stream
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file to process"
+// @Param request body object false "JSON request with base64 PDF and steps array"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Param steps formData string true "JSON array of steps, or comma separated operation names, e.g. repair,optimize"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 408 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/pipeline [post]
+func Pipeline(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "processed",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	steps, stepsErr := pipelineRequestSteps(ctx)
+	if stepsErr != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(stepsErr))
+	}
+
+	if stepErr := runPipeline(ctx.Context(), steps, result.InputPath, result.OutputPath, result.Password); stepErr != nil {
+		return helper.SendErrorResponse(ctx, stepErr.code, stepErr.message)
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// runPipeline runs steps on inFile, opened with password, each on the file the step
+// before wrote, and the last one writes outFile.
+func runPipeline(ctx context.Context, steps []pipelineRequestStep, inFile, outFile, password string) *stepError {
+	workPrefix := strings.TrimSuffix(outFile, filepath.Ext(outFile))
+	for i, step := range steps {
+		if ctx.Err() != nil {
+			return newStepError(fiber.StatusRequestTimeout, "Pipeline cancelled")
+		}
+
+		stepFile := outFile
+		if i < len(steps)-1 {
+			stepFile = fmt.Sprintf("%s_step%d.pdf", workPrefix, i+1)
+			defer os.Remove(stepFile)
+		}
+
+		var stepErr *stepError
+		password, stepErr = pipelineSteps[step.Operation](ctx, inFile, stepFile, password, step.Options)
+		if stepErr != nil {
+			return newStepError(stepErr.code, fmt.Sprintf("Step %d (%s): %s", i+1, step.Operation, stepErr.message))
+		}
+
+		inFile = stepFile
+		reportJobProgress(ctx, i+1, len(steps))
+	}
+
+	return nil
+}
+
+// pipelineRequestSteps reads the steps, sent as a JSON array in the body or in a
+// multipart form as a JSON array or a comma separated list of operation names.
+func pipelineRequestSteps(ctx fiber.Ctx) ([]pipelineRequestStep, error) {
+	var raw []json.RawMessage
+	if strings.HasPrefix(ctx.Get("Content-Type"), "multipart/form-data") {
+		value := strings.TrimSpace(ctx.FormValue("steps"))
+		if strings.HasPrefix(value, "[") {
+			if err := json.Unmarshal([]byte(value), &raw); err != nil {
+				return nil, fmt.Errorf("invalid steps: %v", err)
+			}
+		} else if value != "" {
+			for _, name := range strings.Split(value, ",") {
+				step, _ := json.Marshal(strings.TrimSpace(name))
+				raw = append(raw, step)
+			}
+		}
+	} else {
+		var request struct {
+			Steps []json.RawMessage `json:"steps"`
+		}
+		if err := ctx.Bind().Body(&request); err != nil {
+			return nil, fmt.Errorf("invalid steps: %v", err)
+		}
+		raw = request.Steps
+	}
+
+	return parsePipelineSteps(raw)
+}
+
+// parsePipelineSteps reads the steps raw, operation names or step objects.
+func parsePipelineSteps(raw []json.RawMessage) ([]pipelineRequestStep, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("steps are required")
+	}
+	if len(raw) > maxPipelineSteps {
+		return nil, fmt.Errorf("a pipeline has at most %d steps", maxPipelineSteps)
+	}
+
+	steps := make([]pipelineRequestStep, len(raw))
+	for i, data := range raw {
+		step := &steps[i]
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+			if err := json.Unmarshal(data, &step.Operation); err != nil {
+				return nil, fmt.Errorf("invalid step %d: %v", i+1, err)
+			}
+		} else if err := json.Unmarshal(data, step); err != nil {
+			return nil, fmt.Errorf("invalid step %d: %v", i+1, err)
+		}
+
+		step.Operation = strings.Trim(strings.ToLower(step.Operation), "/")
+		if _, ok := pipelineSteps[step.Operation]; !ok {
+			return nil, fmt.Errorf("unknown operation %q in step %d, use one of %s",
+				step.Operation, i+1, strings.Join(slices.Sorted(maps.Keys(pipelineSteps)), ", "))
+		}
+	}
+
+	return steps, nil
+}
@@ -0,0 +1,213 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"pdftool/internal/pdftest"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v3"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func TestRunPipeline(t *testing.T) {
+	plain := pdftest.TextPDF(t, 3)
+	encrypted := filepath.Join(t.TempDir(), "encrypted.pdf")
+	if err := api.EncryptFile(plain, encrypted, model.NewAESConfiguration("secret", "secret", 256)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		input        string
+		password     string
+		steps        string
+		wantPassword string
+		wantKeyBits  int
+		wantPages    int
+	}{
+		{
+			name:         "protected input",
+			input:        encrypted,
+			password:     "secret",
+			steps:        `["repair", "optimize", {"operation": "watermark", "options": {"type": "text", "text": "DRAFT"}}, {"operation": "encrypt", "options": {"password": "new"}}]`,
+			wantPassword: "new",
+			wantKeyBits:  256,
+			wantPages:    3,
+		},
+		{
+			name:         "protected input kept encrypted",
+			input:        encrypted,
+			password:     "secret",
+			steps:        `[{"operation": "rotate", "options": {"rotation": 90}}, {"operation": "pages", "options": {"operation": "remove", "pages": "1"}}, {"operation": "metadata", "options": {"title": "Test"}}]`,
+			wantPassword: "secret",
+			wantKeyBits:  256,
+			wantPages:    2,
+		},
+		{
+			name:         "steps after encrypt",
+			input:        plain,
+			steps:        `[{"operation": "encrypt", "options": {"password": "new", "algorithm": "aes-128"}}, "optimize", "repair", {"operation": "rotate", "options": {"page_angles": {"2": 180}}}, {"operation": "pages", "options": {"operation": "extract", "pages": "1-2"}}]`,
+			wantPassword: "new",
+			wantKeyBits:  128,
+			wantPages:    2,
+		},
+		{
+			name:      "re-encrypt and decrypt",
+			input:     encrypted,
+			password:  "secret",
+			steps:     `[{"operation": "reencrypt", "options": {"new_user_password": "other", "new_owner_password": "other"}}, "optimize", "decrypt"]`,
+			wantPages: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outFile := filepath.Join(t.TempDir(), "out.pdf")
+			if stepErr := runPipeline(context.Background(), parseTestSteps(t, tt.steps), tt.input, outFile, tt.password); stepErr != nil {
+				t.Fatalf("runPipeline: %d %s", stepErr.code, stepErr.message)
+			}
+
+			pdfCtx, err := readFormContext(outFile, tt.wantPassword, model.VALIDATE)
+			if err != nil {
+				t.Fatalf("reading output with password %q: %v", tt.wantPassword, err)
+			}
+			if pdfCtx.PageCount != tt.wantPages {
+				t.Errorf("got %d pages, want %d", pdfCtx.PageCount, tt.wantPages)
+			}
+
+			if tt.wantPassword == "" {
+				if pdfCtx.E != nil {
+					t.Error("output is encrypted")
+				}
+				return
+			}
+			if pdfCtx.E == nil {
+				t.Fatal("output is not encrypted")
+			}
+			if pdfCtx.E.L != tt.wantKeyBits {
+				t.Errorf("got %d bit key, want %d", pdfCtx.E.L, tt.wantKeyBits)
+			}
+			if _, err := readFormContext(outFile, "", model.VALIDATE); err == nil {
+				t.Error("output opens without a password")
+			}
+		})
+	}
+}
+
+func TestRunPipelineErrors(t *testing.T) {
+	plain := pdftest.TextPDF(t, 2)
+	encrypted := filepath.Join(t.TempDir(), "encrypted.pdf")
+	if err := api.EncryptFile(plain, encrypted, model.NewAESConfiguration("secret", "secret", 256)); err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		input       string
+		password    string
+		steps       string
+		wantCode    int
+		wantMessage string
+	}{
+		{"wrong password", context.Background(), encrypted, "wrong", `["optimize"]`, fiber.StatusBadRequest, "Step 1 (optimize): "},
+		{"missing password", context.Background(), encrypted, "", `["repair"]`, fiber.StatusBadRequest, "Step 1 (repair): "},
+		{"decrypt plain file", context.Background(), plain, "", `["repair", "decrypt"]`, fiber.StatusBadRequest, "Step 2 (decrypt): Password is required"},
+		{"invalid options", context.Background(), plain, "", `[{"operation": "rotate", "options": {"rotation": 45}}]`, fiber.StatusBadRequest, "Step 1 (rotate): Rotation must be"},
+		{"cancelled", cancelled, plain, "", `["repair"]`, fiber.StatusRequestTimeout, "Pipeline cancelled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outFile := filepath.Join(t.TempDir(), "out.pdf")
+			stepErr := runPipeline(tt.ctx, parseTestSteps(t, tt.steps), tt.input, outFile, tt.password)
+			if stepErr == nil {
+				t.Fatal("runPipeline succeeded")
+			}
+			if stepErr.code != tt.wantCode || !strings.HasPrefix(stepErr.message, tt.wantMessage) {
+				t.Errorf("got %d %q, want %d %q", stepErr.code, stepErr.message, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestRunPipelineRepair(t *testing.T) {
+	valid := pdftest.TextPDF(t, 2)
+	outFile := filepath.Join(t.TempDir(), "out.pdf")
+	if stepErr := runPipeline(context.Background(), parseTestSteps(t, `["repair"]`), valid, outFile, ""); stepErr != nil {
+		t.Fatalf("runPipeline: %d %s", stepErr.code, stepErr.message)
+	}
+	in, err := os.ReadFile(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(in, out) {
+		t.Error("repair step rewrote a file that needs no repair")
+	}
+}
+
+func TestParsePipelineSteps(t *testing.T) {
+	tests := []struct {
+		steps   string
+		want    []string
+		wantErr string
+	}{
+		{steps: `["Repair", {"operation": "/form/fill/"}]`, want: []string{"repair", "form/fill"}},
+		{steps: `[]`, wantErr: "steps are required"},
+		{steps: `["repair", "split"]`, wantErr: `unknown operation "split" in step 2`},
+		{steps: `[1]`, wantErr: "invalid step 1"},
+		{steps: `[` + strings.Repeat(`"repair", `, maxPipelineSteps) + `"repair"]`, wantErr: "at most"},
+	}
+
+	for _, tt := range tests {
+		var raw []json.RawMessage
+		if err := json.Unmarshal([]byte(tt.steps), &raw); err != nil {
+			t.Fatal(err)
+		}
+
+		steps, err := parsePipelineSteps(raw)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want %q", tt.steps, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.steps, err)
+			continue
+		}
+		var got []string
+		for _, step := range steps {
+			got = append(got, step.Operation)
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: got %v, want %v", tt.steps, got, tt.want)
+		}
+	}
+}
+
+func parseTestSteps(t *testing.T, steps string) []pipelineRequestStep {
+	t.Helper()
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(steps), &raw); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parsePipelineSteps(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
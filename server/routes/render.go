@@ -0,0 +1,323 @@
+package routes
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"pdftool/server/helper"
+	"pdftool/server/render"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultRenderDPI = 150
+	maxRenderDPI     = 600
+	// Pages rendering to more pixels than this are rejected
+	maxRenderPixels = 50_000_000
+	// Requests selecting more pages than this are rejected
+	maxRenderPages = 100
+)
+
+var errRenderTooLarge = errors.New("rendered page too large")
+
+// @Summary Render pages to images
+// @Description Rasterizes the selected pages to PNG or JPEG at the given resolution, or scaled down to max_width pixels. Annotations with an appearance, such as form fields, are drawn on top of the page.
+// @Description Returns the image of a single page, or a ZIP archive with one image per page when several pages are selected.
+// @Description At most 100 pages are rendered per request.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce image/png,image/jpeg,application/zip
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file to render"
+// @Param request body object false "JSON request with base64 PDF"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Param pages formData string false "Page selection, defaults to all pages, at most 100"
+// @Param format formData string false "Image format, png (default) or jpeg"
+// @Param dpi formData int false "Resolution in dots per inch (default 150, max 600)"
+// @Param max_width formData int false "Maximum image width in pixels, lowers the resolution of wider pages"
+// @Param quality formData int false "JPEG quality 1-100 (default 90)"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 408 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/render [post]
+func Render(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "render",
+		OutputExt:       ".zip",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	var opts struct {
+		Pages    string `json:"pages" form:"pages"`
+		Format   string `json:"format" form:"format"`
+		DPI      int    `json:"dpi" form:"dpi"`
+		MaxWidth int    `json:"max_width" form:"max_width"`
+		Quality  int    `json:"quality" form:"quality"`
+	}
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid render options")
+	}
+
+	ext := ".png"
+	switch strings.ToLower(opts.Format) {
+	case "", "png":
+	case "jpeg", "jpg":
+		ext = ".jpg"
+	default:
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid format, use png or jpeg")
+	}
+	if opts.DPI == 0 {
+		opts.DPI = defaultRenderDPI
+	}
+	if opts.DPI < 1 || opts.DPI > maxRenderDPI {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, fmt.Sprintf("Invalid dpi, use 1 to %d", maxRenderDPI))
+	}
+	if opts.MaxWidth < 0 {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid max_width")
+	}
+	if opts.Quality == 0 {
+		opts.Quality = defaultJPEGQuality
+	}
+	if opts.Quality < 1 || opts.Quality > 100 {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid quality, use 1 to 100")
+	}
+
+	selectedPages, selErr := api.ParsePageSelection(opts.Pages)
+	if selErr != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid page selection")
+	}
+
+	pdfCtx, readErr := readFormContext(result.InputPath, result.Password, model.EXTRACTCONTENT)
+	if readErr != nil {
+		log.Error().Err(readErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			"File is invalid, corrupted or the password is wrong.",
+		)
+	}
+
+	pb, boxErr := pdfCtx.PageBoundaries(nil)
+	if boxErr != nil {
+		log.Error().Err(boxErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			helper.TransformPDFCPUErrorToResponse(boxErr),
+		)
+	}
+
+	pageNrs, scales, pagesErr := renderScales(pb, selectedPages, opts.DPI, opts.MaxWidth)
+	if pagesErr != nil {
+		if errors.Is(pagesErr, errRenderTooLarge) {
+			return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Rendered page exceeds the pixel limit, lower dpi or max_width")
+		}
+		log.Error().Err(pagesErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			helper.TransformPDFCPUErrorToResponse(pagesErr),
+		)
+	}
+	if len(pageNrs) == 0 {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "No pages selected")
+	}
+	if len(pageNrs) > maxRenderPages {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, fmt.Sprintf("Too many pages selected, render at most %d pages per request", maxRenderPages))
+	}
+
+	doc, closeDoc, openErr := openRenderDocument(ctx.Context(), pdfCtx, result.InputPath, true)
+	if openErr != nil {
+		if ctx.Context().Err() != nil {
+			return helper.SendErrorResponse(ctx, fiber.StatusRequestTimeout, "Rendering cancelled")
+		}
+		log.Error().Err(openErr).Caller().Send()
+		return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, "Failed to open file for rendering")
+	}
+	defer closeDoc()
+	baseName := strings.TrimSuffix(result.OutputName, filepath.Ext(result.OutputName))
+
+	if len(pageNrs) == 1 {
+		// A single page is sent as the image itself, named by its format
+		imagePath := strings.TrimSuffix(result.OutputPath, filepath.Ext(result.OutputPath)) + ext
+		defer os.Remove(imagePath)
+
+		if _, _, err := doc.RenderPage(ctx.Context(), pageNrs[0], scales[0]*72, imagePath, opts.Quality); err != nil {
+			if ctx.Context().Err() != nil {
+				return helper.SendErrorResponse(ctx, fiber.StatusRequestTimeout, "Rendering cancelled")
+			}
+			log.Error().Err(err).Caller().Send()
+			return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, fmt.Sprintf("Failed to render page %d", pageNrs[0]))
+		}
+		return ctx.Download(imagePath, baseName+ext)
+	}
+
+	pagesDir, dirErr := os.MkdirTemp("/tmp", "render-*")
+	if dirErr != nil {
+		log.Error().Err(dirErr).Caller().Send()
+		return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, "Failed to create temporary directory")
+	}
+	defer os.RemoveAll(pagesDir)
+
+	files := make([]string, 0, len(pageNrs))
+	for i, pageNr := range pageNrs {
+		if ctx.Context().Err() != nil {
+			return helper.SendErrorResponse(ctx, fiber.StatusRequestTimeout, "Rendering cancelled")
+		}
+
+		file := filepath.Join(pagesDir, fmt.Sprintf("%s_page_%d%s", baseName, pageNr, ext))
+		if _, _, err := doc.RenderPage(ctx.Context(), pageNr, scales[i]*72, file, opts.Quality); err != nil {
+			if ctx.Context().Err() != nil {
+				return helper.SendErrorResponse(ctx, fiber.StatusRequestTimeout, "Rendering cancelled")
+			}
+			log.Error().Err(err).Caller().Send()
+			return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, fmt.Sprintf("Failed to render page %d", pageNr))
+		}
+		files = append(files, file)
+	}
+
+	if err := helper.ZipFiles(result.OutputPath, files); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, "Failed to create ZIP archive")
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// renderScales returns the selected page numbers in order with the scale, in pixels
+// per point, each is rendered at, pb holding the boundaries of all pages. It fails
+// with errRenderTooLarge before anything is rendered if a page exceeds maxRenderPixels.
+func renderScales(pb []model.PageBoundaries, selectedPages []string, dpi, maxWidth int) ([]int, []float64, error) {
+	pageNrs, err := selectedPageNrs(len(pb), selectedPages)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scales := make([]float64, len(pageNrs))
+	for i, pageNr := range pageNrs {
+		box := pb[pageNr-1].CropBox()
+		if box == nil || box.Width() <= 0 || box.Height() <= 0 {
+			return nil, nil, fmt.Errorf("page %d has no valid media box", pageNr)
+		}
+		w, h := box.Width(), box.Height()
+		if pb[pageNr-1].Rot%180 != 0 {
+			w, h = h, w
+		}
+
+		scale := float64(dpi) / 72
+		if maxWidth > 0 && w*scale > float64(maxWidth) {
+			scale = float64(maxWidth) / w
+		}
+		if math.Ceil(w*scale)*math.Ceil(h*scale) > maxRenderPixels {
+			return nil, nil, errRenderTooLarge
+		}
+		scales[i] = scale
+	}
+
+	return pageNrs, scales, nil
+}
+
+// selectedPageNrs returns the numbers of the selected pages of a document with
+// pageCount pages in order, all pages if selectedPages is empty.
+func selectedPageNrs(pageCount int, selectedPages []string) ([]int, error) {
+	pages, err := api.PagesForPageSelection(pageCount, selectedPages, true, true)
+	if err != nil {
+		return nil, err
+	}
+	pageNrs := make([]int, 0, len(pages))
+	for pageNr, selected := range pages {
+		if selected {
+			pageNrs = append(pageNrs, pageNr)
+		}
+	}
+	slices.Sort(pageNrs)
+	return pageNrs, nil
+}
+
+// openRenderDocument opens the document pdfCtx, read from inFile, in a render worker and
+// returns it with the function closing it. The worker reads inFile itself unless it is
+// encrypted, or has annotations that are to be drawn: MuPDF only draws page content, so
+// pdfCtx is written to a temporary file then, decrypted, and with annotations set with
+// their appearances drawn into the pages.
+func openRenderDocument(ctx context.Context, pdfCtx *model.Context, inFile string, annotations bool) (*render.Document, func(), error) {
+	annotations = annotations && hasAnnotations(pdfCtx)
+	if pdfCtx.Encrypt == nil && !annotations {
+		doc, err := render.Open(ctx, inFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return doc, func() { doc.Close() }, nil
+	}
+
+	if annotations {
+		if err := flattenAnnotations(pdfCtx, func(string) bool { return true }); err != nil {
+			return nil, nil, err
+		}
+	}
+	if pdfCtx.Encrypt != nil {
+		pdfCtx.Cmd = model.DECRYPT
+		fixStreamLengths(pdfCtx)
+	}
+	renderFile := filepath.Join("/tmp", rand.Text()+"_render.pdf")
+	if err := api.WriteContextFile(pdfCtx, renderFile); err != nil {
+		os.Remove(renderFile)
+		return nil, nil, err
+	}
+
+	doc, err := render.Open(ctx, renderFile)
+	if err != nil {
+		os.Remove(renderFile)
+		return nil, nil, err
+	}
+	return doc, func() {
+		doc.Close()
+		os.Remove(renderFile)
+	}, nil
+}
+
+// fixStreamLengths sets the Length of the streams of pdfCtx to their decrypted size,
+// which pdfcpu keeps at the encrypted one when reading them.
+func fixStreamLengths(pdfCtx *model.Context) {
+	for _, entry := range pdfCtx.XRefTable.Table {
+		if entry == nil || entry.Free {
+			continue
+		}
+		if sd, ok := entry.Object.(types.StreamDict); ok && sd.Raw != nil {
+			l := int64(len(sd.Raw))
+			sd.StreamLength = &l
+			sd.Dict["Length"] = types.Integer(l)
+			entry.Object = sd
+		}
+	}
+}
+
+// hasAnnotations reports whether a page of pdfCtx has annotations.
+func hasAnnotations(pdfCtx *model.Context) bool {
+	for pageNr := 1; pageNr <= pdfCtx.PageCount; pageNr++ {
+		pageDict, _, _, err := pdfCtx.PageDict(pageNr, false)
+		if err != nil {
+			continue
+		}
+		if annots, err := pdfCtx.DereferenceArray(pageDict["Annots"]); err == nil && len(annots) > 0 {
+			return true
+		}
+	}
+	return false
+}
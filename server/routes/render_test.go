@@ -0,0 +1,86 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"pdftool/internal/pdftest"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func TestOpenRenderDocument(t *testing.T) {
+	// A red page with a blue widget in its upper half
+	var b pdftest.Builder
+	content := b.Add(pdftest.Stream("", "1 0 0 rg 0 0 100 100 re f"))
+	appearance := b.Add(pdftest.Stream("/Type /XObject /Subtype /Form /BBox [0 0 10 10]", "0 0 1 rg 0 0 10 10 re f"))
+	widget := b.Reserve()
+	root := b.Document(fmt.Sprintf("/MediaBox [0 0 100 100] /Contents %d 0 R /Annots [%d 0 R]", content, widget))
+	b.Set(widget, fmt.Sprintf("<< /Type /Annot /Subtype /Widget /Rect [0 50 100 100] /AP << /N %d 0 R >> >>", appearance))
+	plain := b.Write(t, root)
+
+	encrypted := filepath.Join(t.TempDir(), "encrypted.pdf")
+	if err := api.EncryptFile(plain, encrypted, model.NewAESConfiguration("secret", "secret", 256)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		file        string
+		password    string
+		annotations bool
+		// Colors of the upper and lower half
+		top, bottom [3]uint8
+	}{
+		{"annotations", plain, "", true, [3]uint8{0, 0, 255}, [3]uint8{255, 0, 0}},
+		{"page content only", plain, "", false, [3]uint8{255, 0, 0}, [3]uint8{255, 0, 0}},
+		{"encrypted", encrypted, "secret", true, [3]uint8{0, 0, 255}, [3]uint8{255, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdfCtx, err := readFormContext(tt.file, tt.password, model.EXTRACTCONTENT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, closeDoc, err := openRenderDocument(context.Background(), pdfCtx, tt.file, tt.annotations)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closeDoc()
+
+			imagePath := filepath.Join(t.TempDir(), "page.png")
+			if _, _, err := doc.RenderPage(context.Background(), 1, 72, imagePath, 0); err != nil {
+				t.Fatal(err)
+			}
+			img := readTestPNG(t, imagePath)
+			for _, p := range []struct {
+				at   image.Point
+				want [3]uint8
+			}{{image.Pt(50, 25), tt.top}, {image.Pt(50, 75), tt.bottom}} {
+				r, g, b, _ := img.At(p.at.X, p.at.Y).RGBA()
+				if got := [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)}; got != p.want {
+					t.Errorf("got color %v at %v, want %v", got, p.at, p.want)
+				}
+			}
+		})
+	}
+}
+
+func readTestPNG(t *testing.T, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
@@ -14,6 +14,7 @@ import (
 // @Produce octet-stream
 // @Security ApiKeyAuth
 // @Param file formData file false "PDF file to repair"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
 // @Param request body object false "JSON request with base64 PDF"
 // @Success 200 {file} binary
 // @Failure 400 {object} types.Response
@@ -30,7 +31,7 @@ func Repair(ctx fiber.Ctx) error {
 	}
 	defer result.Cleanup()
 
-	if err := api.ValidateFile(result.InputPath, nil); err == nil {
+	if !needsRepair(result.InputPath, result.Password) {
 		return helper.SendErrorResponse(
 			ctx,
 			fiber.StatusBadRequest,
@@ -38,13 +39,24 @@ func Repair(ctx fiber.Ctx) error {
 		)
 	}
 
-	if err := api.OptimizeFile(result.InputPath, result.OutputPath, nil); err != nil {
-		return helper.SendErrorResponse(
-			ctx,
-			fiber.StatusBadRequest,
-			helper.TransformPDFCPUErrorToResponse(err),
-		)
+	if stepErr := repairPDF(result.InputPath, result.OutputPath, result.Password); stepErr != nil {
+		return helper.SendErrorResponse(ctx, stepErr.code, stepErr.message)
 	}
 
 	return ctx.Download(result.OutputPath, result.OutputName)
 }
+
+// needsRepair reports whether inFile, opened with password, fails validation.
+func needsRepair(inFile, password string) bool {
+	return api.ValidateFile(inFile, passwordConfiguration(password)) != nil
+}
+
+// repairPDF rewrites inFile, opened with password, to outFile, which fixes what pdfcpu
+// can read past.
+func repairPDF(inFile, outFile, password string) *stepError {
+	if err := api.OptimizeFile(inFile, outFile, passwordConfiguration(password)); err != nil {
+		return newStepError(fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(err))
+	}
+
+	return nil
+}
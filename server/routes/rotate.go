@@ -0,0 +1,192 @@
+package routes
+
+import (
+	"fmt"
+	"os"
+	"pdftool/server/helper"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v3"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/rs/zerolog/log"
+)
+
+type rotateOptions struct {
+	Rotation   int            `json:"rotation" form:"rotation"`
+	Pages      string         `json:"pages" form:"pages"`
+	PageAngles map[string]int `json:"page_angles" form:"-"`
+}
+
+// @Summary Rotate pages
+// @Description Rotates all or selected pages clockwise by 90, 180 or 270 degrees.
+// @Description page_angles maps page numbers to their own angle, e.g. {"1": 90, "3": 270}, and takes precedence over rotation.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce octet-stream
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file to rotate"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Param request body object false "JSON request with base64 PDF"
+// @Param rotation formData int false "Rotation for the selected pages: 90, 180 or 270"
+// @Param pages formData string false "Page selection for rotation, defaults to all pages"
+// @Param page_angles formData string false "JSON object of page number to angle"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/rotate [post]
+func Rotate(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "rotated",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	var opts rotateOptions
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid rotate options")
+	}
+
+	pageAngles, anglesErr := rotatePageAngles(ctx)
+	if anglesErr != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(anglesErr))
+	}
+	opts.PageAngles = pageAngles
+
+	if stepErr := rotatePDF(result.InputPath, result.OutputPath, result.Password, opts); stepErr != nil {
+		return helper.SendErrorResponse(ctx, stepErr.code, stepErr.message)
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// rotatePDF rotates the pages of inFile, opened with password, as opts tell and writes
+// outFile.
+func rotatePDF(inFile, outFile, password string, opts rotateOptions) *stepError {
+	pageAngles, anglesErr := parsePageAngles(opts.PageAngles)
+	if anglesErr != nil {
+		return newStepError(fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(anglesErr))
+	}
+
+	if opts.Rotation == 0 && len(pageAngles) == 0 {
+		return newStepError(fiber.StatusBadRequest, "Rotation or page_angles is required")
+	}
+
+	if opts.Rotation != 0 && !validRotation(opts.Rotation) {
+		return newStepError(fiber.StatusBadRequest, "Rotation must be 90, 180 or 270")
+	}
+
+	selectedPages, selErr := api.ParsePageSelection(opts.Pages)
+	if selErr != nil {
+		return newStepError(fiber.StatusBadRequest, "Invalid page selection")
+	}
+
+	if err := api.ValidateFile(inFile, passwordConfiguration(password)); err != nil {
+		return newStepError(fiber.StatusBadRequest, "File is invalid, corrupted or the password is wrong.")
+	}
+
+	if err := rotateFile(inFile, outFile, password, opts.Rotation, selectedPages, pageAngles); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return newStepError(fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(err))
+	}
+
+	return nil
+}
+
+// rotatePageAngles reads the optional page_angles object, sent as JSON in the body
+// or as a JSON string field in a multipart form.
+func rotatePageAngles(ctx fiber.Ctx) (map[string]int, error) {
+	var raw map[string]int
+	if strings.HasPrefix(ctx.Get("Content-Type"), "multipart/form-data") {
+		if value := ctx.FormValue("page_angles"); value != "" {
+			if err := json.Unmarshal([]byte(value), &raw); err != nil {
+				return nil, fmt.Errorf("invalid page_angles: %v", err)
+			}
+		}
+	} else {
+		var request struct {
+			PageAngles map[string]int `json:"page_angles"`
+		}
+		if err := ctx.Bind().Body(&request); err != nil {
+			return nil, fmt.Errorf("invalid page_angles: %v", err)
+		}
+		raw = request.PageAngles
+	}
+
+	return raw, nil
+}
+
+// parsePageAngles checks the page numbers and angles of the page_angles object.
+func parsePageAngles(raw map[string]int) (map[int]int, error) {
+	pageAngles := make(map[int]int, len(raw))
+	for page, angle := range raw {
+		pageNr, err := strconv.Atoi(page)
+		if err != nil || pageNr < 1 {
+			return nil, fmt.Errorf("invalid page number in page_angles: %s", page)
+		}
+		if !validRotation(angle) {
+			return nil, fmt.Errorf("invalid angle for page %d: must be 90, 180 or 270", pageNr)
+		}
+		pageAngles[pageNr] = angle
+	}
+
+	return pageAngles, nil
+}
+
+func validRotation(rotation int) bool {
+	return rotation == 90 || rotation == 180 || rotation == 270
+}
+
+// rotateFile rotates selectedPages by rotation, then applies the per page angles,
+// in a single read and write of inFile, opened with password.
+func rotateFile(inFile, outFile, password string, rotation int, selectedPages []string, pageAngles map[int]int) error {
+	f, err := os.Open(inFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	conf := passwordConfiguration(password)
+	conf.Cmd = model.ROTATE
+
+	pdfCtx, err := api.ReadValidateAndOptimize(f, conf)
+	if err != nil {
+		return err
+	}
+
+	if rotation != 0 {
+		pages, err := api.PagesForPageSelection(pdfCtx.PageCount, selectedPages, true, true)
+		if err != nil {
+			return err
+		}
+
+		// Pages with their own angle are left to the loop below
+		for pageNr := range pageAngles {
+			delete(pages, pageNr)
+		}
+
+		if err := pdfcpu.RotatePages(pdfCtx, pages, rotation); err != nil {
+			return err
+		}
+	}
+
+	for pageNr, angle := range pageAngles {
+		if pageNr > pdfCtx.PageCount {
+			return fmt.Errorf("page %d does not exist, document has %d pages", pageNr, pdfCtx.PageCount)
+		}
+
+		if err := pdfcpu.RotatePages(pdfCtx, types.IntSet{pageNr: true}, angle); err != nil {
+			return err
+		}
+	}
+
+	return api.WriteContextFile(pdfCtx, outFile)
+}
@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"pdftool/server/helper"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/rs/zerolog/log"
+)
+
+// @Summary Split a PDF file
+// @Description Splits a PDF file every N pages or by explicit page ranges, and returns the parts as a ZIP archive
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce application/zip
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file to split"
+// @Param request body object false "JSON request with base64 PDF"
+// @Param span formData int false "Split every N pages (default 1)"
+// @Param ranges formData string false "Comma separated page ranges, e.g. 1-3,4,5-10"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/split [post]
+func Split(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "split",
+		OutputExt:       ".zip",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	var opts struct {
+		Span   int    `json:"span" form:"span"`
+		Ranges string `json:"ranges" form:"ranges"`
+	}
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid split options")
+	}
+
+	if err := api.ValidateFile(result.InputPath, nil); err != nil {
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			"File is invalid or corrupted. Please upload a valid PDF.",
+		)
+	}
+
+	ranges, rangeErr := splitRanges(result.InputPath, opts.Ranges, opts.Span)
+	if rangeErr != nil {
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			helper.TransformPDFCPUErrorToResponse(rangeErr),
+		)
+	}
+
+	partsDir, dirErr := os.MkdirTemp("/tmp", "split-*")
+	if dirErr != nil {
+		log.Error().Err(dirErr).Caller().Send()
+		return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, "Failed to create temporary directory")
+	}
+	defer os.RemoveAll(partsDir)
+
+	baseName := strings.TrimSuffix(result.OutputName, filepath.Ext(result.OutputName))
+	parts := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		part := filepath.Join(partsDir, fmt.Sprintf("%s_%s.pdf", baseName, r))
+		if err := api.CollectFile(result.InputPath, part, []string{r}, nil); err != nil {
+			log.Error().Err(err).Caller().Send()
+			return helper.SendErrorResponse(
+				ctx,
+				fiber.StatusBadRequest,
+				helper.TransformPDFCPUErrorToResponse(err),
+			)
+		}
+		parts = append(parts, part)
+	}
+
+	if err := helper.ZipFiles(result.OutputPath, parts); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, "Failed to create ZIP archive")
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// splitRanges returns the page ranges to cut, either the explicit ranges given
+// or consecutive ranges of span pages covering the whole document.
+func splitRanges(inputPath, ranges string, span int) ([]string, error) {
+	pageCount, err := api.PageCountFile(inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if ranges != "" {
+		var out []string
+		for _, r := range strings.Split(ranges, ",") {
+			r = strings.TrimSpace(r)
+			if r == "" {
+				continue
+			}
+			if _, err := api.PagesForPageCollection(pageCount, []string{r}); err != nil {
+				return nil, fmt.Errorf("invalid page range: %s", r)
+			}
+			out = append(out, r)
+		}
+		if len(out) == 0 {
+			return nil, fmt.Errorf("invalid page range: %s", ranges)
+		}
+		return out, nil
+	}
+
+	if span < 0 {
+		return nil, fmt.Errorf("span must be a positive number")
+	}
+	if span == 0 {
+		span = 1
+	}
+
+	var out []string
+	for from := 1; from <= pageCount; from += span {
+		thru := min(from+span-1, pageCount)
+		if from == thru {
+			out = append(out, fmt.Sprintf("%d", from))
+			continue
+		}
+		out = append(out, fmt.Sprintf("%d-%d", from, thru))
+	}
+	return out, nil
+}
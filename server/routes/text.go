@@ -0,0 +1,136 @@
+package routes
+
+import (
+	"pdftool/server/helper"
+	"pdftool/types"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/rs/zerolog/log"
+)
+
+// textPage is one entry of the /v1/text response.
+type textPage struct {
+	Page int    `json:"page"`
+	Text string `json:"text"`
+}
+
+// @Summary Extract text
+// @Description Extracts the embedded text layer of the selected pages, without OCR, so scanned pages come back empty. Text of annotations is left out.
+// @Description Returns a list of pages with their text as JSON, or with format=text the text of all pages as text/plain,
+// @Description separated by form feeds. With layout, the text is spaced out to resemble the page.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce json,plain
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file"
+// @Param request body object false "JSON request with base64 PDF"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Param pages formData string false "Page selection, defaults to all pages"
+// @Param format formData string false "Response format, json (default) or text"
+// @Param layout formData bool false "Preserve the layout of the page"
+// @Success 200 {object} types.Response
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 408 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/text [post]
+func Text(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "text",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	var opts struct {
+		Pages  string `json:"pages" form:"pages"`
+		Format string `json:"format" form:"format"`
+		Layout bool   `json:"layout" form:"layout"`
+	}
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid text options")
+	}
+
+	plain := false
+	switch strings.ToLower(opts.Format) {
+	case "", "json":
+	case "text", "txt", "plain":
+		plain = true
+	default:
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid format, use json or text")
+	}
+
+	selectedPages, selErr := api.ParsePageSelection(opts.Pages)
+	if selErr != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid page selection")
+	}
+
+	pdfCtx, readErr := readFormContext(result.InputPath, result.Password, model.EXTRACTCONTENT)
+	if readErr != nil {
+		log.Error().Err(readErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			"File is invalid, corrupted or the password is wrong.",
+		)
+	}
+
+	pageNrs, pagesErr := selectedPageNrs(pdfCtx.PageCount, selectedPages)
+	if pagesErr != nil {
+		log.Error().Err(pagesErr).Caller().Send()
+		return helper.SendErrorResponse(
+			ctx,
+			fiber.StatusBadRequest,
+			helper.TransformPDFCPUErrorToResponse(pagesErr),
+		)
+	}
+	if len(pageNrs) == 0 {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "No pages selected")
+	}
+
+	doc, closeDoc, openErr := openRenderDocument(ctx.Context(), pdfCtx, result.InputPath, false)
+	if openErr != nil {
+		if ctx.Context().Err() != nil {
+			return helper.SendErrorResponse(ctx, fiber.StatusRequestTimeout, "Text extraction cancelled")
+		}
+		log.Error().Err(openErr).Caller().Send()
+		return helper.SendErrorResponse(ctx, fiber.StatusInternalServerError, "Failed to open file for text extraction")
+	}
+	defer closeDoc()
+
+	pages := make([]textPage, 0, len(pageNrs))
+	for _, pageNr := range pageNrs {
+		text, textErr := doc.PageText(ctx.Context(), pageNr, opts.Layout)
+		if textErr != nil {
+			if ctx.Context().Err() != nil {
+				return helper.SendErrorResponse(ctx, fiber.StatusRequestTimeout, "Text extraction cancelled")
+			}
+			log.Error().Err(textErr).Caller().Send()
+			return helper.SendErrorResponse(
+				ctx,
+				fiber.StatusInternalServerError,
+				helper.TransformPDFCPUErrorToResponse(textErr),
+			)
+		}
+		pages = append(pages, textPage{Page: pageNr, Text: text})
+	}
+
+	if plain {
+		texts := make([]string, len(pages))
+		for i, page := range pages {
+			texts[i] = page.Text
+		}
+		ctx.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return ctx.SendString(strings.Join(texts, "\f"))
+	}
+
+	return ctx.JSON(types.Response{
+		Error: false,
+		Data:  pages,
+	})
+}
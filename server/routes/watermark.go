@@ -0,0 +1,249 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"pdftool/server/helper"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/color"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/rs/zerolog/log"
+)
+
+// watermarkPositions are the anchors pdfcpu places watermarks at.
+var watermarkPositions = []string{"tl", "tc", "tr", "l", "c", "r", "bl", "bc", "br"}
+
+type watermarkOptions struct {
+	Type     string   `json:"type" form:"type"` // text or image
+	Mode     string   `json:"mode" form:"mode"` // watermark (behind content) or stamp (on top)
+	Pages    string   `json:"pages" form:"pages"`
+	Text     string   `json:"text" form:"text"`
+	Font     string   `json:"font" form:"font"`
+	FontSize *int     `json:"font_size" form:"font_size"`
+	Color    string   `json:"color" form:"color"`
+	Opacity  *float64 `json:"opacity" form:"opacity"`
+	Rotation *float64 `json:"rotation" form:"rotation"`
+	Position string   `json:"position" form:"position"`
+	Offset   string   `json:"offset" form:"offset"`
+	Scale    string   `json:"scale" form:"scale"`
+	Image    string   `json:"base64_image"`
+}
+
+// @Summary Add a text or image watermark
+// @Description Stamps text or a PNG/JPEG image onto all or selected pages.
+// @Description mode "watermark" puts it behind the page content, "stamp" on top of it.
+// @Tags PDF Operations
+// @Accept multipart/form-data,application/json
+// @Produce octet-stream
+// @Security ApiKeyAuth
+// @Param file formData file false "PDF file to watermark"
+// @Param pdf_password formData string false "Password of an encrypted PDF"
+// @Param request body object false "JSON request with base64 PDF, base64_image for image watermarks"
+// @Param type formData string true "text or image"
+// @Param mode formData string false "watermark (default) or stamp"
+// @Param pages formData string false "Page selection, defaults to all pages"
+// @Param text formData string false "Text to stamp"
+// @Param font formData string false "Font name, e.g. Helvetica, Times-Roman, Courier"
+// @Param font_size formData int false "Font size in points"
+// @Param color formData string false "Text color, e.g. #FF0000"
+// @Param opacity formData number false "Opacity between 0 and 1"
+// @Param rotation formData number false "Rotation in degrees, defaults to diagonal"
+// @Param position formData string false "Anchor: tl, tc, tr, l, c, r, bl, bc, br"
+// @Param offset formData string false "Offset from the anchor in points, e.g. \"10 -10\""
+// @Param scale formData string false "Scale factor, e.g. 0.5 rel or 1 abs"
+// @Param image formData file false "PNG or JPEG image to stamp"
+// @Success 200 {file} binary
+// @Failure 400 {object} types.Response
+// @Failure 401 {object} types.Response
+// @Failure 500 {object} types.Response
+// @Router /v1/watermark [post]
+func Watermark(ctx fiber.Ctx) error {
+	result, err := helper.ProcessPDFRequest(ctx, helper.PDFProcessOptions{
+		RequirePassword: false,
+		OutputPrefix:    "watermarked",
+	})
+	if err != nil {
+		return helper.SendErrorResponse(ctx, err.Code, err.Message)
+	}
+	defer result.Cleanup()
+
+	var opts watermarkOptions
+	if err := ctx.Bind().Body(&opts); err != nil {
+		return helper.SendErrorResponse(ctx, fiber.StatusBadRequest, "Invalid watermark options")
+	}
+
+	image := func() (io.ReadCloser, error) { return watermarkImage(ctx, opts.Image) }
+	if stepErr := watermarkPDF(result.InputPath, result.OutputPath, result.Password, opts, image); stepErr != nil {
+		return helper.SendErrorResponse(ctx, stepErr.code, stepErr.message)
+	}
+
+	return ctx.Download(result.OutputPath, result.OutputName)
+}
+
+// watermarkPDF stamps the watermark opts describe onto inFile, opened with password, and
+// writes outFile. image opens the image of image watermarks.
+func watermarkPDF(inFile, outFile, password string, opts watermarkOptions, image func() (io.ReadCloser, error)) *stepError {
+	var onTop bool
+	switch opts.Mode {
+	case "", "watermark":
+		onTop = false
+	case "stamp":
+		onTop = true
+	default:
+		return newStepError(fiber.StatusBadRequest, "Invalid mode. Use watermark or stamp")
+	}
+
+	selectedPages, selErr := api.ParsePageSelection(opts.Pages)
+	if selErr != nil {
+		return newStepError(fiber.StatusBadRequest, "Invalid page selection")
+	}
+
+	if stepErr := opts.validate(); stepErr != nil {
+		return stepErr
+	}
+
+	var (
+		wm    *model.Watermark
+		wmErr error
+	)
+	switch opts.Type {
+	case "text":
+		if opts.Text == "" {
+			return newStepError(fiber.StatusBadRequest, "Text is required")
+		}
+		wm, wmErr = api.TextWatermark(opts.Text, opts.description(true), onTop, false, types.POINTS)
+	case "image":
+		r, imageErr := image()
+		if imageErr != nil {
+			return newStepError(fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(imageErr))
+		}
+		defer r.Close()
+		wm, wmErr = api.ImageWatermarkForReader(r, opts.description(false), onTop, false, types.POINTS)
+	default:
+		return newStepError(fiber.StatusBadRequest, "Invalid type. Use text or image")
+	}
+	if wmErr != nil {
+		return newStepError(fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(wmErr))
+	}
+
+	if err := api.ValidateFile(inFile, passwordConfiguration(password)); err != nil {
+		return newStepError(fiber.StatusBadRequest, "File is invalid, corrupted or the password is wrong.")
+	}
+
+	if err := api.AddWatermarksFile(inFile, outFile, selectedPages, wm, passwordConfiguration(password)); err != nil {
+		log.Error().Err(err).Caller().Send()
+		return newStepError(fiber.StatusBadRequest, helper.TransformPDFCPUErrorToResponse(err))
+	}
+
+	return nil
+}
+
+// validate checks the options pasted into the description, so none can add parameters
+// of its own.
+func (o watermarkOptions) validate() *stepError {
+	if o.Font != "" && !font.SupportedFont(o.Font) {
+		return newStepError(fiber.StatusBadRequest, "Invalid font, use a standard font like Helvetica, Times-Roman or Courier")
+	}
+	if o.Color != "" {
+		if _, err := color.ParseColor(o.Color); err != nil || strings.ContainsAny(o.Color, ",:") {
+			return newStepError(fiber.StatusBadRequest, "Invalid color, use a hex color like #FF0000")
+		}
+	}
+	if o.Position != "" && !slices.Contains(watermarkPositions, o.Position) {
+		return newStepError(fiber.StatusBadRequest, "Invalid position. Use tl, tc, tr, l, c, r, bl, bc or br")
+	}
+	if o.Offset != "" {
+		d := strings.Fields(o.Offset)
+		if len(d) != 2 || !isNumber(d[0]) || !isNumber(d[1]) {
+			return newStepError(fiber.StatusBadRequest, "Invalid offset, use two numbers like \"10 -10\"")
+		}
+	}
+	if o.Scale != "" {
+		d := strings.Fields(o.Scale)
+		if len(d) == 0 || len(d) > 2 || !isNumber(d[0]) || len(d) == 2 && d[1] != "rel" && d[1] != "abs" {
+			return newStepError(fiber.StatusBadRequest, "Invalid scale, use a factor like 0.5 rel or 1 abs")
+		}
+	}
+	return nil
+}
+
+// isNumber reports whether s is a decimal number.
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// description builds the pdfcpu watermark description string, e.g. "fontname:Helvetica, points:48".
+func (o watermarkOptions) description(text bool) string {
+	var params []string
+	if text {
+		if o.Font != "" {
+			params = append(params, "fontname:"+o.Font)
+		}
+		if o.FontSize != nil {
+			params = append(params, fmt.Sprintf("points:%d", *o.FontSize))
+		}
+		if o.Color != "" {
+			params = append(params, "fillcolor:"+o.Color)
+		}
+	}
+	if o.Opacity != nil {
+		params = append(params, fmt.Sprintf("opacity:%g", *o.Opacity))
+	}
+	if o.Rotation != nil {
+		params = append(params, fmt.Sprintf("rotation:%g", *o.Rotation))
+	}
+	if o.Position != "" {
+		params = append(params, "position:"+o.Position)
+	}
+	if o.Offset != "" {
+		params = append(params, "offset:"+o.Offset)
+	}
+	if o.Scale != "" {
+		params = append(params, "scalefactor:"+o.Scale)
+	}
+
+	return strings.Join(params, ", ")
+}
+
+// watermarkImage returns the uploaded "image" form file or the decoded base64 image.
+func watermarkImage(ctx fiber.Ctx, base64Image string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(ctx.Get("Content-Type"), "multipart/form-data") {
+		return decodeWatermarkImage(base64Image)
+	}
+
+	file, err := ctx.FormFile("image")
+	if err != nil {
+		return nil, fmt.Errorf("no image uploaded")
+	}
+
+	switch file.Header.Get("Content-Type") {
+	case "image/png", "image/jpeg":
+	default:
+		return nil, fmt.Errorf("invalid image type. Only PNG and JPEG images are allowed")
+	}
+
+	return file.Open()
+}
+
+// decodeWatermarkImage returns the image sent as base64 in JSON requests.
+func decodeWatermarkImage(base64Image string) (io.ReadCloser, error) {
+	if base64Image == "" {
+		return nil, fmt.Errorf("image data cannot be empty")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(base64Image)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 image data")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
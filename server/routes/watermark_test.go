@@ -0,0 +1,26 @@
+package routes
+
+import "testing"
+
+func TestWatermarkOptionsValidate(t *testing.T) {
+	tests := []struct {
+		opts    watermarkOptions
+		wantErr bool
+	}{
+		{opts: watermarkOptions{Font: "Helvetica", Color: "#FF0000", Position: "bl", Offset: "10 -10", Scale: "0.5 rel"}},
+		{opts: watermarkOptions{Scale: "1"}},
+		{opts: watermarkOptions{Font: "Helvetica, points:400"}, wantErr: true},
+		{opts: watermarkOptions{Font: "NoSuchFont"}, wantErr: true},
+		{opts: watermarkOptions{Color: "#FF0000, opacity:1"}, wantErr: true},
+		{opts: watermarkOptions{Position: "c, rotation:0"}, wantErr: true},
+		{opts: watermarkOptions{Position: "middle"}, wantErr: true},
+		{opts: watermarkOptions{Offset: "10 -10, scalefactor:20"}, wantErr: true},
+		{opts: watermarkOptions{Scale: "0.5 rel, opacity:0"}, wantErr: true},
+		{opts: watermarkOptions{Scale: "0.5 big"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if stepErr := tt.opts.validate(); (stepErr != nil) != tt.wantErr {
+			t.Errorf("%+v: got error %v, want error %v", tt.opts, stepErr, tt.wantErr)
+		}
+	}
+}
@@ -33,7 +33,9 @@ func New() *fiber.App {
 	}
 
 	app := fiber.New(appCfg)
-	app.Use(cors.New())
+	app.Use(cors.New(cors.Config{
+		ExposeHeaders: []string{"X-Original-Size", "X-Optimized-Size"},
+	}))
 	app.Use(favicon.New())
 	app.Use(helmet.New())
 	app.Use(earlydata.New())
@@ -1,6 +1,10 @@
 package types
 
-import "github.com/gofiber/storage/minio"
+import (
+	"time"
+
+	"github.com/gofiber/storage/minio"
+)
 
 const (
 	AppName          string = "pdfTool"
@@ -25,6 +29,7 @@ var Config struct {
 	Keys struct {
 		API     string `yaml:"api_key" env:"API_KEY"`
 		Mistral string `yaml:"mistral" env:"MISTRAL"`
+		Webhook string `yaml:"webhook" env:"WEBHOOK_SECRET"` // signs job callbacks, which are disabled without it
 	} `yaml:"keys"`
 
 	Swagger struct {
@@ -33,13 +38,33 @@ var Config struct {
 	} `yaml:"swagger"`
 
 	S3 struct {
-		Enable   bool   `yaml:"enable" env:"S3_ENABLE" env-default:"false"`
-		Endpoint string `yaml:"endpoint" env:"S3_ENDPOINT"`
-		Bucket   string `yaml:"bucket" env:"S3_BUCKET"`
-		Key      struct {
+		Enable        bool          `yaml:"enable" env:"S3_ENABLE" env-default:"false"`
+		Endpoint      string        `yaml:"endpoint" env:"S3_ENDPOINT"`
+		Bucket        string        `yaml:"bucket" env:"S3_BUCKET"`
+		PresignExpiry time.Duration `yaml:"presign_expiry" env:"S3_PRESIGN_EXPIRY" env-default:"10m"` // lifetime of the presigned URLs to the private objects
+		Key           struct {
 			Access string `yaml:"access" env:"S3_ACCESS"`
 			Secret string `yaml:"secret" env:"S3_SECRET"`
 		} `yaml:"key"`
 		Storage *minio.Storage
 	} `yaml:"s3"`
+
+	Jobs struct {
+		Workers   int           `yaml:"workers" env:"JOB_WORKERS" env-default:"2"`
+		QueueSize int           `yaml:"queue_size" env:"JOB_QUEUE_SIZE" env-default:"100"` // jobs waiting for a worker, more are refused
+		Retention time.Duration `yaml:"retention" env:"JOB_RETENTION" env-default:"1h"`    // how long finished jobs and their results are kept
+		Timeout   time.Duration `yaml:"timeout" env:"JOB_TIMEOUT" env-default:"30m"`       // how long jobs of operations without a time limit of their own may run
+		// Networks in CIDR notation callbacks may reach though they are not public, e.g. 10.0.0.0/8
+		CallbackNetworks []string `yaml:"callback_networks" env:"JOB_CALLBACK_NETWORKS" env-separator:","`
+	} `yaml:"jobs"`
+
+	OCR struct {
+		Provider  string `yaml:"provider" env:"OCR_PROVIDER" env-default:"mistral"` // mistral or tesseract, which runs locally and is only in the tesseract image
+		Inline    bool   `yaml:"inline" env:"OCR_INLINE" env-default:"false"`       // send documents to mistral in the request instead of through S3
+		Tesseract struct {
+			Path string `yaml:"path" env:"TESSERACT_PATH" env-default:"tesseract"`
+			Lang string `yaml:"lang" env:"TESSERACT_LANG" env-default:"eng"`
+			DPI  int    `yaml:"dpi" env:"TESSERACT_DPI" env-default:"300"`
+		} `yaml:"tesseract"`
+	} `yaml:"ocr"`
 }